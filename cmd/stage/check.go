@@ -0,0 +1,70 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/cb-demos/stage/internal/check"
+	"github.com/cb-demos/stage/internal/config"
+	"github.com/cb-demos/stage/internal/transformer"
+)
+
+// runCheck validates the configuration and asset directory without serving
+// and writes a report for CI. It returns 0 if the checks passed, 1 if they
+// failed and 2 if they could not run.
+func runCheck(args []string) int {
+	flags := flag.NewFlagSet("check", flag.ContinueOnError)
+	configFile := flags.String("config", os.Getenv("CONFIG_FILE"), configFlagUsage)
+	format := flags.String("format", check.FormatText, "report format: text, json or junit")
+	output := flags.String("output", "", "file to write the report to (default: standard output)")
+	strict := flags.Bool("strict", false, "fail on warnings as well as errors")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: stage check [--config FILE] [--format text|json|junit] [--output FILE] [--strict]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	// Keep standard output for the report
+	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{
+		Level: getLogLevel(),
+	})))
+
+	var report check.Report
+	cfg, err := config.LoadFile(*configFile)
+	if err != nil {
+		report = check.ConfigFailure(err)
+	} else {
+		opts := append(check.TransformerOptions(cfg), transformer.WithCompression(false), transformer.WithCacheLimit(0))
+		trans := newTransformer(cfg, opts...)
+		report, err = check.Run(cfg, trans, check.Options{Strict: *strict})
+		if err != nil {
+			slog.Error("Failed to check assets", "error", err)
+			return 2
+		}
+	}
+
+	out := os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			slog.Error("Failed to create report", "error", err)
+			return 2
+		}
+		defer f.Close()
+		out = f
+	}
+
+	if err := report.Write(out, *format); err != nil {
+		slog.Error("Failed to write report", "error", err)
+		return 2
+	}
+
+	if !report.Passed {
+		return 1
+	}
+	return 0
+}
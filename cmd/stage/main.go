@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"flag"
+	"fmt"
 	"log/slog"
 	"os"
 	"os/signal"
@@ -20,32 +22,58 @@ func main() {
 	}))
 	slog.SetDefault(logger)
 
+	// Subcommands run once and exit instead of serving
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "render":
+			os.Exit(runRender(os.Args[2:]))
+		case "check":
+			os.Exit(runCheck(os.Args[2:]))
+		}
+	}
+
+	configFile := flag.String("config", os.Getenv("CONFIG_FILE"), configFlagUsage)
+	flag.Parse()
+
 	slog.Info("Starting stage - intelligent web server")
 
 	// Load configuration
-	cfg, err := config.Load()
+	cfg, err := config.LoadFile(*configFile)
 	if err != nil {
 		slog.Error("Failed to load configuration", "error", err)
 		os.Exit(1)
 	}
 
-	slog.Info("Configuration loaded",
-		"port", cfg.Port,
-		"assetDir", cfg.AssetDir,
-		"fmKeyConfigured", cfg.FMKey != "",
-		"replacementCount", len(cfg.Replacements),
-		"prometheusEnabled", cfg.PrometheusEnabled,
-		"prometheusScenario", cfg.PrometheusScenario)
+	logConfig(cfg)
 
-	// Create transformer and run transformations
-	trans := transformer.New(cfg.AssetDir, cfg.Replacements)
-	if err := trans.TransformAll(); err != nil {
+	// Transform the assets of every site before serving any of them
+	current, err := transformSites(cfg)
+	if err != nil {
 		slog.Error("Failed to transform assets", "error", err)
 		os.Exit(1)
 	}
 
+	// Watchers of every generation of sites stop on shutdown
+	watchCtx, stopWatching := context.WithCancel(context.Background())
+	defer stopWatching()
+
 	// Create and start server
-	srv := server.New(cfg, trans.GetCache(), logger)
+	srv := server.New(cfg, current.trans.GetCache(), logger)
+	current.serve(srv)
+
+	reload := &reloader{configFile: *configFile, srv: srv, ctx: watchCtx, startup: cfg}
+	reload.start(current)
+	srv.SetReloader(reload.reload)
+
+	// Reload the configuration on SIGHUP, as with POST /__stage/reload
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			slog.Info("Reloading on SIGHUP")
+			_ = srv.Reload() // the outcome is logged
+		}
+	}()
 
 	// Setup graceful shutdown
 	go func() {
@@ -61,6 +89,7 @@ func main() {
 	<-quit
 
 	slog.Info("Shutting down server...")
+	stopWatching()
 
 	// Perform graceful shutdown with context
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -73,6 +102,75 @@ func main() {
 	slog.Info("Server stopped")
 }
 
+// configFlagUsage describes the --config flag shared by all commands
+const configFlagUsage = "path to a stage.yaml or stage.toml config file"
+
+// logConfig logs the settings of a loaded configuration
+func logConfig(cfg *config.Config) {
+	slog.Info("Configuration loaded",
+		"configFile", cfg.File,
+		"profile", cfg.Profile,
+		"port", cfg.Port,
+		"assetDir", cfg.AssetDir,
+		"fmKeyConfigured", cfg.FMKey != "",
+		"replacementCount", len(cfg.Replacements),
+		"prometheusEnabled", cfg.PrometheusEnabled,
+		"prometheusScenario", cfg.PrometheusScenario,
+		"placeholderPattern", cfg.PlaceholderPattern,
+		"strictPlaceholders", cfg.StrictPlaceholders,
+		"escapeValues", cfg.EscapeValues,
+		"cacheMaxMB", cfg.CacheMaxMB,
+		"hotReload", cfg.HotReload,
+		"cspNonce", cfg.CSPNonce,
+		"requestValues", len(cfg.RequestValues),
+		"hosts", cfg.HostNames(),
+		"reloadAPI", cfg.ReloadToken != "")
+}
+
+// transformAssets creates a transformer for cfg that leaves per-response
+// values to the server and transforms its assets, failing if that fails or,
+// in strict mode, leaves placeholders behind
+func transformAssets(cfg *config.Config, opts ...transformer.Option) (*transformer.Transformer, error) {
+	perResponse := []transformer.Option{
+		transformer.WithCSPNonce(cfg.CSPNonce),
+		transformer.WithRequestPlaceholders(cfg.RequestNames()...),
+	}
+	trans := newTransformer(cfg, append(perResponse, opts...)...)
+	if err := trans.TransformAll(); err != nil {
+		return nil, err
+	}
+
+	// In strict mode, refuse to serve assets that still contain placeholders
+	if unresolved := trans.GetCache().Unresolved(); len(unresolved) > 0 && cfg.StrictPlaceholders {
+		return nil, fmt.Errorf("%d unresolved placeholders found in strict mode", len(unresolved))
+	}
+
+	return trans, nil
+}
+
+// newTransformer creates a transformer from the configuration; opts are
+// applied last and override it. CSP nonces and request values are only
+// filled in by the server, so without transformAssets or
+// check.TransformerOptions their placeholders are audited like any other.
+func newTransformer(cfg *config.Config, opts ...transformer.Option) *transformer.Transformer {
+	openDelim, closeDelim := cfg.Delimiters()
+	options := []transformer.Option{
+		transformer.WithDelimiters(openDelim, closeDelim),
+		transformer.WithIgnoredPlaceholders(cfg.IgnorePlaceholders...),
+		transformer.WithRuntimeEnvAllowlist(cfg.RuntimeEnvAllowlist...),
+		transformer.WithRules(transformer.Rules{
+			Include: cfg.TransformInclude,
+			Exclude: cfg.TransformExclude,
+			Sniff:   cfg.TransformSniff,
+		}),
+		transformer.WithEscaping(cfg.EscapeValues),
+		transformer.WithRawPlaceholders(cfg.RawPlaceholders...),
+		transformer.WithCompression(cfg.Compression),
+		transformer.WithCacheLimit(cfg.CacheMaxMB * 1024 * 1024),
+	}
+	return transformer.New(cfg.AssetDir, cfg.Replacements, append(options, opts...)...)
+}
+
 // getLogLevel returns the log level based on environment variable
 func getLogLevel() slog.Level {
 	level := os.Getenv("LOG_LEVEL")
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/cb-demos/stage/internal/config"
+	"github.com/cb-demos/stage/internal/server"
+	"github.com/cb-demos/stage/internal/transformer"
+)
+
+// sites are the transformers of one configuration: one for the default site
+// and one for each virtual host, in the order of cfg.Hosts
+type sites struct {
+	cfg   *config.Config
+	trans *transformer.Transformer
+	hosts []*transformer.Transformer
+}
+
+// transformSites transforms the assets of the default site and of every
+// virtual host of cfg, each into a fresh cache
+func transformSites(cfg *config.Config) (*sites, error) {
+	trans, err := transformAssets(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &sites{cfg: cfg, trans: trans}
+	for _, host := range cfg.Hosts {
+		hostTrans, err := transformAssets(cfg.ForHost(host), transformer.WithHost(host.Name))
+		if err != nil {
+			return nil, fmt.Errorf("host %s: %w", host.Name, err)
+		}
+		s.hosts = append(s.hosts, hostTrans)
+	}
+	return s, nil
+}
+
+// serve makes srv serve the sites
+func (s *sites) serve(srv *server.Server) {
+	hostCaches := make(map[string]*transformer.Cache, len(s.hosts))
+	for i, host := range s.cfg.Hosts {
+		hostCaches[host.Name] = s.hosts[i].GetCache()
+	}
+	srv.Swap(s.cfg, s.trans.GetCache(), hostCaches)
+}
+
+// watch keeps the caches in sync with their asset directories until ctx is
+// done, if hot reload is enabled
+func (s *sites) watch(ctx context.Context) {
+	if !s.cfg.HotReload {
+		return
+	}
+	for _, trans := range append([]*transformer.Transformer{s.trans}, s.hosts...) {
+		go func() {
+			if err := trans.Watch(ctx, s.cfg.HotReloadDebounce); err != nil {
+				slog.Error("Hot reload disabled", "error", err)
+			}
+		}()
+	}
+}
+
+// reloader re-reads the configuration and swaps freshly transformed sites
+// into the server. The server runs one reload at a time.
+type reloader struct {
+	configFile string
+	srv        *server.Server
+	ctx        context.Context // ends the watchers of every generation
+	startup    *config.Config  // settings that only change on restart
+
+	stopWatching context.CancelFunc // stops the watchers of the current sites
+}
+
+// start watches the asset directories of s in place of the previous sites
+func (r *reloader) start(s *sites) {
+	if r.stopWatching != nil {
+		r.stopWatching()
+	}
+	ctx, cancel := context.WithCancel(r.ctx)
+	r.stopWatching = cancel
+	s.watch(ctx)
+}
+
+// reload implements server.ReloadFunc. The config file and value files are
+// read again; environment variables are those the process started with.
+func (r *reloader) reload() error {
+	cfg, err := config.LoadFile(r.configFile)
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	logConfig(cfg)
+
+	next, err := transformSites(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to transform assets: %w", err)
+	}
+
+	warnRestartRequired(r.startup, cfg)
+
+	next.serve(r.srv)
+	r.start(next)
+	return nil
+}
+
+// warnRestartRequired logs settings that changed since startup but only take
+// effect on restart
+func warnRestartRequired(startup, cfg *config.Config) {
+	changed := map[string]bool{
+		"PORT":                      cfg.Port != startup.Port,
+		"HOST":                      cfg.Host != startup.Host,
+		"PROMETHEUS_ENABLED":        cfg.PrometheusEnabled != startup.PrometheusEnabled,
+		"STAGE_PROMETHEUS_SCENARIO": cfg.PrometheusScenario != startup.PrometheusScenario,
+	}
+	for setting, ok := range changed {
+		if ok {
+			slog.Warn("Setting changed, restart to apply it", "setting", setting)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/cb-demos/stage/internal/config"
+	"github.com/cb-demos/stage/internal/transformer"
+)
+
+// runRender transforms the asset directory once and writes the result to
+// --out, so environment-specific builds can be uploaded to a CDN or object
+// store without running the server. It returns the process exit code.
+func runRender(args []string) int {
+	flags := flag.NewFlagSet("render", flag.ContinueOnError)
+	configFile := flags.String("config", os.Getenv("CONFIG_FILE"), configFlagUsage)
+	outDir := flags.String("out", "", "directory to write the rendered assets to (must be empty or not exist)")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: stage render --out DIR [--config FILE]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *outDir == "" {
+		fmt.Fprintln(flags.Output(), "render: --out is required")
+		flags.Usage()
+		return 2
+	}
+
+	cfg, err := config.LoadFile(*configFile)
+	if err != nil {
+		slog.Error("Failed to load configuration", "error", err)
+		return 1
+	}
+
+	slog.Info("Rendering assets",
+		"configFile", cfg.File,
+		"profile", cfg.Profile,
+		"assetDir", cfg.AssetDir,
+		"out", *outDir,
+		"replacementCount", len(cfg.Replacements))
+
+	// Every file is written once, so keep them all and skip compression
+	trans := newTransformer(cfg, transformer.WithCompression(false), transformer.WithCacheLimit(0))
+	if err := trans.TransformAll(); err != nil {
+		slog.Error("Failed to transform assets", "error", err)
+		return 1
+	}
+
+	if unresolved := trans.GetCache().Unresolved(); len(unresolved) > 0 && cfg.StrictPlaceholders {
+		slog.Error("Unresolved placeholders found in strict mode", "count", len(unresolved))
+		return 1
+	}
+
+	stats, err := trans.Render(*outDir)
+	if err != nil {
+		slog.Error("Failed to render assets", "error", err)
+		return 1
+	}
+
+	slog.Info("Assets rendered",
+		"out", *outDir,
+		"transformed", stats.Transformed,
+		"copied", stats.Copied,
+		"bytes", stats.Bytes)
+	return 0
+}
@@ -0,0 +1,149 @@
+// Package check validates a deployment before it goes out: the
+// configuration, the placeholders in the asset directory and the values
+// configured for them.
+package check
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/cb-demos/stage/internal/config"
+	"github.com/cb-demos/stage/internal/prometheus"
+	"github.com/cb-demos/stage/internal/transformer"
+)
+
+// Severities of findings
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Checks, in report order
+const (
+	CheckConfig     = "config"
+	CheckUnresolved = "unresolved-placeholders"
+	CheckUnused     = "unused-replacements"
+	CheckPrometheus = "prometheus-scenario"
+	CheckFileTypes  = "file-types"
+)
+
+// allChecks are the checks run once the configuration has loaded
+var allChecks = []string{CheckUnresolved, CheckUnused, CheckPrometheus, CheckFileTypes}
+
+// Finding is a problem found by a check
+type Finding struct {
+	Check    string `json:"check"`
+	Severity string `json:"severity"`
+	File     string `json:"file,omitempty"`
+	Line     int    `json:"line,omitempty"`
+	Message  string `json:"message"`
+}
+
+// Report is the outcome of a check run
+type Report struct {
+	Passed   bool      `json:"passed"`
+	Errors   int       `json:"errors"`
+	Warnings int       `json:"warnings"`
+	Checks   []string  `json:"checks"`
+	Findings []Finding `json:"findings"`
+}
+
+// Options tune how findings are graded
+type Options struct {
+	// Strict fails the check on warnings as well as errors
+	Strict bool
+}
+
+// TransformerOptions are the options the transformer passed to Run needs
+// beyond those for rendering: the server fills CSP nonces and request values
+// per response, so their placeholders are not reported as unresolved
+func TransformerOptions(cfg *config.Config) []transformer.Option {
+	return []transformer.Option{
+		transformer.WithCSPNonce(cfg.CSPNonce),
+		transformer.WithRequestPlaceholders(cfg.RequestNames()...),
+	}
+}
+
+// Run transforms the asset directory with trans, built from cfg, and checks
+// the result. Errors are only returned when the assets cannot be read.
+func Run(cfg *config.Config, trans *transformer.Transformer, opts Options) (Report, error) {
+	var findings []Finding
+
+	// Required placeholders without a value and unknown filters fail the
+	// transformation, but stay in the files and are reported below
+	if err := trans.TransformAll(); err != nil && !transformer.IsPlaceholderError(err) {
+		return Report{}, err
+	}
+
+	for _, u := range trans.GetCache().Unresolved() {
+		findings = append(findings, Finding{
+			Check:    CheckUnresolved,
+			Severity: SeverityError,
+			File:     u.File,
+			Line:     u.Line,
+			Message:  fmt.Sprintf("placeholder %s was not replaced", u.Token),
+		})
+	}
+
+	for _, name := range trans.GetCache().UsageReport(cfg.Replacements, cfg.RuntimeEnvAllowlist).Unused {
+		findings = append(findings, Finding{
+			Check:    CheckUnused,
+			Severity: SeverityWarning,
+			Message:  fmt.Sprintf("replacement %s matches no placeholder", name),
+		})
+	}
+
+	if cfg.PrometheusEnabled && !slices.Contains(prometheus.ValidScenarioTypes(), cfg.PrometheusScenario) {
+		findings = append(findings, Finding{
+			Check:    CheckPrometheus,
+			Severity: SeverityError,
+			Message: fmt.Sprintf("STAGE_PROMETHEUS_SCENARIO %q is not a scenario, use one of %v",
+				cfg.PrometheusScenario, prometheus.ValidScenarioTypes()),
+		})
+	}
+
+	issues, err := trans.CheckFiles()
+	if err != nil {
+		return Report{}, err
+	}
+	for _, issue := range issues {
+		findings = append(findings, Finding{
+			Check:    CheckFileTypes,
+			Severity: SeverityWarning,
+			File:     issue.File,
+			Line:     issue.Line,
+			Message:  issue.Message,
+		})
+	}
+
+	return newReport(allChecks, findings, opts), nil
+}
+
+// ConfigFailure reports a configuration that failed to load
+func ConfigFailure(err error) Report {
+	return newReport([]string{CheckConfig}, []Finding{{
+		Check:    CheckConfig,
+		Severity: SeverityError,
+		Message:  err.Error(),
+	}}, Options{})
+}
+
+// newReport grades findings and counts them
+func newReport(checks []string, findings []Finding, opts Options) Report {
+	report := Report{Checks: checks, Findings: []Finding{}}
+
+	for _, f := range findings {
+		if opts.Strict {
+			f.Severity = SeverityError
+		}
+		if f.Severity == SeverityError {
+			report.Errors++
+		} else {
+			report.Warnings++
+		}
+		report.Findings = append(report.Findings, f)
+	}
+
+	report.Passed = report.Errors == 0
+	return report
+}
@@ -0,0 +1,203 @@
+package check
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cb-demos/stage/internal/config"
+	"github.com/cb-demos/stage/internal/transformer"
+)
+
+func writeAssets(t *testing.T, files map[string]string) string {
+	t.Helper()
+	assetDir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(assetDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to create file: %v", err)
+		}
+	}
+	return assetDir
+}
+
+func TestRun(t *testing.T) {
+	assetDir := writeAssets(t, map[string]string{
+		"index.html":       "<title>__TITLE__</title>\n<p>__MISSING__</p>",
+		"site.webmanifest": "{\"name\": \"__TITLE__\"}",
+	})
+
+	tests := []struct {
+		name         string
+		replacements map[string]string
+		runtimeEnv   []string
+		scenario     string
+		strict       bool
+		passed       bool
+		counts       map[string]int
+		errors       int
+		warnings     int
+	}{
+		{
+			name:         "unresolved placeholder",
+			replacements: map[string]string{"TITLE": "Demo"},
+			scenario:     "healthy",
+			passed:       false,
+			counts:       map[string]int{CheckUnresolved: 1, CheckFileTypes: 1},
+			errors:       1,
+			warnings:     1,
+		},
+		{
+			name:         "unused replacement",
+			replacements: map[string]string{"TITLE": "Demo", "MISSING": "x", "EXTRA": "y"},
+			scenario:     "healthy",
+			passed:       true,
+			counts:       map[string]int{CheckUnused: 1, CheckFileTypes: 1},
+			warnings:     2,
+		},
+		{
+			name:         "replacement in the runtime env",
+			replacements: map[string]string{"TITLE": "Demo", "MISSING": "x", "PUBLIC_API_URL": "y"},
+			runtimeEnv:   []string{"PUBLIC_*"},
+			scenario:     "healthy",
+			passed:       true,
+			counts:       map[string]int{CheckFileTypes: 1},
+			warnings:     1,
+		},
+		{
+			name:         "unknown scenario",
+			replacements: map[string]string{"TITLE": "Demo", "MISSING": "x"},
+			scenario:     "meltdown",
+			passed:       false,
+			counts:       map[string]int{CheckPrometheus: 1, CheckFileTypes: 1},
+			errors:       1,
+			warnings:     1,
+		},
+		{
+			name:         "strict",
+			replacements: map[string]string{"TITLE": "Demo", "MISSING": "x"},
+			scenario:     "healthy",
+			strict:       true,
+			passed:       false,
+			counts:       map[string]int{CheckFileTypes: 1},
+			errors:       1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{
+				AssetDir:            assetDir,
+				Replacements:        tt.replacements,
+				RuntimeEnvAllowlist: tt.runtimeEnv,
+				PrometheusEnabled:   true,
+				PrometheusScenario:  tt.scenario,
+			}
+			trans := transformer.New(assetDir, tt.replacements)
+
+			report, err := Run(cfg, trans, Options{Strict: tt.strict})
+			if err != nil {
+				t.Fatalf("Run failed: %v", err)
+			}
+
+			if report.Passed != tt.passed {
+				t.Errorf("expected passed=%v, got %+v", tt.passed, report)
+			}
+			if report.Errors != tt.errors || report.Warnings != tt.warnings {
+				t.Errorf("expected %d error(s) and %d warning(s), got %+v", tt.errors, tt.warnings, report)
+			}
+
+			counts := map[string]int{}
+			for _, f := range report.Findings {
+				counts[f.Check]++
+			}
+			if len(counts) != len(tt.counts) {
+				t.Errorf("expected findings %v, got %+v", tt.counts, report.Findings)
+			}
+			for check, n := range tt.counts {
+				if counts[check] != n {
+					t.Errorf("expected %d %s finding(s), got %+v", n, check, report.Findings)
+				}
+			}
+		})
+	}
+}
+
+func TestRunReportsLocation(t *testing.T) {
+	assetDir := writeAssets(t, map[string]string{
+		"index.html": "<title>Demo</title>\n<p>__REQ:?set REQ__</p>",
+	})
+	cfg := &config.Config{AssetDir: assetDir, Replacements: map[string]string{}}
+
+	report, err := Run(cfg, transformer.New(assetDir, nil), Options{})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if len(report.Findings) != 1 {
+		t.Fatalf("expected 1 finding, got %+v", report.Findings)
+	}
+	f := report.Findings[0]
+	if f.Check != CheckUnresolved || f.File != "index.html" || f.Line != 2 {
+		t.Errorf("unexpected finding %+v", f)
+	}
+}
+
+func TestRunWithPerResponseValues(t *testing.T) {
+	assetDir := writeAssets(t, map[string]string{
+		"index.html": `<meta content="__CSP_NONCE__"><h1>__TENANT__</h1>`,
+	})
+	cfg := &config.Config{
+		AssetDir:      assetDir,
+		Replacements:  map[string]string{},
+		CSPNonce:      true,
+		RequestValues: []config.RequestValue{{Name: "TENANT", Source: config.RequestSubdomain}},
+	}
+
+	report, err := Run(cfg, transformer.New(assetDir, nil, TransformerOptions(cfg)...), Options{})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if !report.Passed || len(report.Findings) != 0 {
+		t.Errorf("expected placeholders filled per response to pass, got %+v", report.Findings)
+	}
+}
+
+func TestRunReturnsReadErrors(t *testing.T) {
+	assetDir := writeAssets(t, map[string]string{"index.html": "<p>__MISSING:?needed__</p>"})
+	trans := transformer.New(assetDir, nil)
+	cfg := &config.Config{AssetDir: assetDir, Replacements: map[string]string{}}
+
+	// Placeholder errors become findings
+	if _, err := Run(cfg, trans, Options{}); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	// Errors reading the assets do not, even with findings cached already
+	if err := os.RemoveAll(assetDir); err != nil {
+		t.Fatalf("failed to remove assets: %v", err)
+	}
+	_, err := Run(cfg, trans, Options{})
+	if err == nil || !strings.Contains(err.Error(), "failed to transform assets") {
+		t.Errorf("expected the transformation to fail, got %v", err)
+	}
+}
+
+func TestConfigFailure(t *testing.T) {
+	report := ConfigFailure(errors.New("invalid PORT"))
+
+	if report.Passed || report.Errors != 1 {
+		t.Errorf("expected a failed report, got %+v", report)
+	}
+	if len(report.Checks) != 1 || report.Checks[0] != CheckConfig {
+		t.Errorf("expected only the config check, got %v", report.Checks)
+	}
+	if report.Findings[0].Message != "invalid PORT" {
+		t.Errorf("unexpected finding %+v", report.Findings[0])
+	}
+}
@@ -0,0 +1,132 @@
+package check
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Formats a report can be written in
+const (
+	FormatText  = "text"
+	FormatJSON  = "json"
+	FormatJUnit = "junit"
+)
+
+// Write writes the report to w in the given format
+func (r Report) Write(w io.Writer, format string) error {
+	switch format {
+	case FormatText, "":
+		return r.writeText(w)
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(r)
+	case FormatJUnit:
+		return r.writeJUnit(w)
+	default:
+		return fmt.Errorf("unknown report format %q, use %s, %s or %s", format, FormatText, FormatJSON, FormatJUnit)
+	}
+}
+
+// writeText writes one line per finding and a summary
+func (r Report) writeText(w io.Writer) error {
+	for _, f := range r.Findings {
+		if _, err := fmt.Fprintf(w, "%s: [%s] %s%s\n", f.Severity, f.Check, f.location(": "), f.Message); err != nil {
+			return err
+		}
+	}
+
+	status := "passed"
+	if !r.Passed {
+		status = "failed"
+	}
+	_, err := fmt.Fprintf(w, "check %s: %d error(s), %d warning(s)\n", status, r.Errors, r.Warnings)
+	return err
+}
+
+// location returns file:line followed by sep, or nothing for findings that
+// are not about a file
+func (f Finding) location(sep string) string {
+	switch {
+	case f.File == "":
+		return ""
+	case f.Line > 0:
+		return f.File + ":" + strconv.Itoa(f.Line) + sep
+	default:
+		return f.File + sep
+	}
+}
+
+// JUnit XML schema, as understood by common CI systems
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Name     string       `xml:"name,attr"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+}
+
+// writeJUnit writes a test suite per check and a test case per finding.
+// Errors are failures; warnings pass with the message as output. A check
+// without findings is a single passing test case.
+func (r Report) writeJUnit(w io.Writer) error {
+	suites := junitSuites{Name: "stage check"}
+
+	for _, check := range r.Checks {
+		suite := junitSuite{Name: check}
+		for _, f := range r.Findings {
+			if f.Check != check {
+				continue
+			}
+			c := junitCase{Name: f.location(": ") + f.Message, ClassName: check}
+			if f.Severity == SeverityError {
+				c.Failure = &junitFailure{Message: f.Message, Type: f.Severity}
+				suite.Failures++
+			} else {
+				c.SystemOut = f.Severity + ": " + f.Message
+			}
+			suite.Cases = append(suite.Cases, c)
+		}
+		if len(suite.Cases) == 0 {
+			suite.Cases = append(suite.Cases, junitCase{Name: check, ClassName: check})
+		}
+		suite.Tests = len(suite.Cases)
+
+		suites.Tests += suite.Tests
+		suites.Failures += suite.Failures
+		suites.Suites = append(suites.Suites, suite)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,86 @@
+package check
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func testReport() Report {
+	return newReport(allChecks, []Finding{
+		{Check: CheckUnresolved, Severity: SeverityError, File: "index.html", Line: 3, Message: "placeholder __API__ was not replaced"},
+		{Check: CheckUnused, Severity: SeverityWarning, Message: "replacement EXTRA matches no placeholder"},
+	}, Options{})
+}
+
+func TestWriteText(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testReport().Write(&buf, FormatText); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	expected := "error: [unresolved-placeholders] index.html:3: placeholder __API__ was not replaced\n" +
+		"warning: [unused-replacements] replacement EXTRA matches no placeholder\n" +
+		"check failed: 1 error(s), 1 warning(s)\n"
+	if buf.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, buf.String())
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testReport().Write(&buf, FormatJSON); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	var decoded Report
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if decoded.Passed || decoded.Errors != 1 || decoded.Warnings != 1 || len(decoded.Findings) != 2 {
+		t.Errorf("unexpected report %+v", decoded)
+	}
+	if decoded.Findings[0].File != "index.html" || decoded.Findings[0].Line != 3 {
+		t.Errorf("expected location to be kept, got %+v", decoded.Findings[0])
+	}
+	if strings.Contains(buf.String(), `"file": ""`) {
+		t.Errorf("expected empty locations to be omitted, got %s", buf.String())
+	}
+}
+
+func TestWriteJUnit(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testReport().Write(&buf, FormatJUnit); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	var suites junitSuites
+	if err := xml.Unmarshal(buf.Bytes(), &suites); err != nil {
+		t.Fatalf("invalid XML: %v", err)
+	}
+
+	if len(suites.Suites) != len(allChecks) {
+		t.Fatalf("expected a suite per check, got %d", len(suites.Suites))
+	}
+	if suites.Tests != 4 || suites.Failures != 1 {
+		t.Errorf("expected 4 tests and 1 failure, got %d and %d", suites.Tests, suites.Failures)
+	}
+
+	unresolved := suites.Suites[0]
+	if unresolved.Name != CheckUnresolved || unresolved.Cases[0].Failure == nil {
+		t.Errorf("expected unresolved placeholder to fail, got %+v", unresolved)
+	}
+	unused := suites.Suites[1]
+	if unused.Failures != 0 || !strings.Contains(unused.Cases[0].SystemOut, "EXTRA") {
+		t.Errorf("expected warning to pass with output, got %+v", unused)
+	}
+}
+
+func TestWriteUnknownFormat(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testReport().Write(&buf, "yaml"); err == nil {
+		t.Error("expected error for unknown format")
+	}
+}
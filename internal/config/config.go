@@ -4,8 +4,10 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
+	"path"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // Config holds the application configuration
@@ -26,21 +28,160 @@ type Config struct {
 	// Transformation rules: map of placeholder -> replacement value
 	// e.g., "FF_SDK_KEY" -> "abc123" means replace "__FF_SDK_KEY__" with "abc123"
 	Replacements map[string]string
+
+	// Replacement values read from files (STAGE_<NAME>_FILE), e.g. mounted secrets
+	// FileValueTrim is "newline" (trailing line breaks), "space" or "none"
+	// FileValueMaxBytes of 0 means unlimited
+	FileValueTrim     string
+	FileValueMaxBytes int
+
+	// PlaceholderPattern is how placeholders are written, with NAME standing
+	// for the name, e.g. "__NAME__" (the default), "${NAME}" or "{{NAME}}"
+	PlaceholderPattern string
+
+	// Unresolved placeholder audit
+	// StrictPlaceholders makes startup fail when transformed files still contain placeholders
+	StrictPlaceholders bool
+	// IgnorePlaceholders lists placeholder names the audit should not report
+	IgnorePlaceholders []string
+
+	// Transformable file selection on top of the built-in extension list
+	// TransformInclude and TransformExclude are glob patterns ("*.map", "vendor/**")
+	TransformInclude []string
+	TransformExclude []string
+	// TransformSniff transforms unknown files whose content looks like text
+	TransformSniff bool
+
+	// Context-aware escaping of replacement values
+	// EscapeValues escapes values for the JSON, JavaScript, HTML or URL context
+	// they are inserted into; RawPlaceholders are always inserted verbatim
+	EscapeValues    bool
+	RawPlaceholders []string
+
+	// CacheMaxMB bounds the memory used by cached files; least recently used
+	// files are evicted and transformed again on request. 0 means unlimited.
+	// Each virtual host has a cache of its own with this budget.
+	CacheMaxMB int
+
+	// Compression precompresses cached files with gzip and brotli at startup
+	Compression bool
+
+	// CacheRules set Cache-Control per path, checked in order before the
+	// built-in defaults (fingerprinted files immutable, everything else no-cache)
+	CacheRules []CacheRule
+
+	// RuntimeEnvAllowlist selects which replacements are exposed to the browser
+	// at /__stage/env.js and /__stage/env.json (glob patterns, e.g. "PUBLIC_*")
+	RuntimeEnvAllowlist []string
+
+	// Hot reload of the asset directory
+	HotReload         bool
+	HotReloadDebounce time.Duration
+
+	// Content-Security-Policy nonces for HTML pages
+	// CSPNonce adds a fresh nonce to the script and style tags of every HTML
+	// response; CSPPolicy is the header sent with it, {nonce} standing for it
+	CSPNonce  bool
+	CSPPolicy string
+
+	// RequestValues fill placeholders per response from the request (a
+	// header, cookie or the host), in the files containing them
+	RequestValues []RequestValue
+
+	// ReloadToken enables POST /__stage/reload for clients sending it as a
+	// bearer token; the endpoint is disabled without one
+	ReloadToken string
+
+	// Hosts serve their own replacements, and optionally their own asset
+	// directory, to requests for them; other requests get the settings above
+	Hosts []VirtualHost
+
+	// File is the config file settings were read from, if any
+	File string
+	// Profile is the config file profile selected by STAGE_PROFILE, whose
+	// replacements are layered over the base ones (e.g. "qa", "prod")
+	Profile string
+	// origins maps settings read from File, by environment variable name, to
+	// their line, so validation errors can point at it
+	origins map[string]int
+}
+
+// DefaultCSPPolicy is the Content-Security-Policy sent with nonces unless
+// CSP_POLICY is set; it only allows scripts and styles carrying the nonce
+const DefaultCSPPolicy = "script-src 'nonce-{nonce}' 'strict-dynamic'; style-src 'self' 'nonce-{nonce}'; object-src 'none'; base-uri 'self'"
+
+// CacheRule maps a glob pattern to a Cache-Control header value
+type CacheRule struct {
+	Pattern      string
+	CacheControl string
 }
 
-// Load reads configuration from environment variables
+// Load reads configuration from environment variables and the optional
+// config file named by CONFIG_FILE
 func Load() (*Config, error) {
+	return LoadFile(os.Getenv("CONFIG_FILE"))
+}
+
+// LoadFile reads configuration from the config file at path, if not empty,
+// and environment variables. Environment variables take precedence over the
+// file, which takes precedence over the defaults. Replacements of the profile
+// named by STAGE_PROFILE take precedence over the base ones in the file, and
+// those of each host over all of them.
+func LoadFile(path string) (*Config, error) {
 	cfg := &Config{
-		Port:               getEnvOrDefault("PORT", "8080"),
-		AssetDir:           getEnvOrDefault("ASSET_DIR", "/app/assets"),
-		Host:               getEnvOrDefault("HOST", "0.0.0.0"),
-		FMKey:              os.Getenv("FM_KEY"), // Optional - used for FM visualization features
-		PrometheusEnabled:  getBoolEnvOrDefault("PROMETHEUS_ENABLED", true),
-		PrometheusScenario: getEnvOrDefault("STAGE_PROMETHEUS_SCENARIO", "healthy"),
+		Port:               "8080",
+		AssetDir:           "/app/assets",
+		Host:               "0.0.0.0",
+		PrometheusEnabled:  true,
+		PrometheusScenario: "healthy",
 		Replacements:       make(map[string]string),
+		PlaceholderPattern: "__NAME__",
+		FileValueTrim:      TrimNewline,
+		FileValueMaxBytes:  64 * 1024,
+		EscapeValues:       true,
+		Compression:        true,
+		HotReloadDebounce:  250 * time.Millisecond,
+		CSPPolicy:          DefaultCSPPolicy,
+	}
+
+	profile := os.Getenv("STAGE_PROFILE")
+	if path != "" {
+		if err := cfg.loadFile(path, profile); err != nil {
+			return nil, err
+		}
+	} else if profile != "" {
+		return nil, fmt.Errorf("STAGE_PROFILE is set to %q but no config file defines profiles, set CONFIG_FILE or --config", profile)
 	}
+	cfg.Profile = profile
+
+	cfg.Port = getEnvOrDefault("PORT", cfg.Port)
+	cfg.AssetDir = getEnvOrDefault("ASSET_DIR", cfg.AssetDir)
+	cfg.Host = getEnvOrDefault("HOST", cfg.Host)
+	cfg.FMKey = os.Getenv("FM_KEY") // Optional - used for FM visualization features
+	cfg.ReloadToken = os.Getenv("RELOAD_TOKEN")
+	cfg.PrometheusEnabled = getBoolEnvOrDefault("PROMETHEUS_ENABLED", cfg.PrometheusEnabled)
+	cfg.PrometheusScenario = getEnvOrDefault("STAGE_PROMETHEUS_SCENARIO", cfg.PrometheusScenario)
+	cfg.FileValueTrim = getEnvOrDefault("FILE_VALUE_TRIM", cfg.FileValueTrim)
+	cfg.FileValueMaxBytes = getIntEnvOrDefault("FILE_VALUE_MAX_BYTES", cfg.FileValueMaxBytes)
+	cfg.PlaceholderPattern = getEnvOrDefault("PLACEHOLDER_PATTERN", cfg.PlaceholderPattern)
+	cfg.StrictPlaceholders = getBoolEnvOrDefault("STRICT_PLACEHOLDERS", cfg.StrictPlaceholders)
+	cfg.IgnorePlaceholders = getListEnvOrDefault("IGNORE_PLACEHOLDERS", cfg.IgnorePlaceholders)
+	cfg.TransformInclude = getListEnvOrDefault("TRANSFORM_INCLUDE", cfg.TransformInclude)
+	cfg.TransformExclude = getListEnvOrDefault("TRANSFORM_EXCLUDE", cfg.TransformExclude)
+	cfg.TransformSniff = getBoolEnvOrDefault("TRANSFORM_SNIFF", cfg.TransformSniff)
+	cfg.EscapeValues = getBoolEnvOrDefault("ESCAPE_VALUES", cfg.EscapeValues)
+	cfg.RawPlaceholders = getListEnvOrDefault("RAW_PLACEHOLDERS", cfg.RawPlaceholders)
+	cfg.CacheMaxMB = getIntEnvOrDefault("CACHE_MAX_MB", cfg.CacheMaxMB)
+	cfg.Compression = getBoolEnvOrDefault("COMPRESSION", cfg.Compression)
+	cfg.RuntimeEnvAllowlist = getListEnvOrDefault("RUNTIME_ENV_ALLOWLIST", cfg.RuntimeEnvAllowlist)
+	cfg.HotReload = getBoolEnvOrDefault("HOT_RELOAD", cfg.HotReload)
+	cfg.HotReloadDebounce = getDurationEnvOrDefault("HOT_RELOAD_DEBOUNCE", cfg.HotReloadDebounce)
+	cfg.CSPNonce = getBoolEnvOrDefault("CSP_NONCE", cfg.CSPNonce)
+	cfg.CSPPolicy = getEnvOrDefault("CSP_POLICY", cfg.CSPPolicy)
+	cfg.dropOverriddenOrigins()
 
 	// Parse all STAGE_* environment variables for transformations
+	valueFiles := make(map[string]string) // placeholder -> path of its value
 	for _, env := range os.Environ() {
 		// Split into key=value
 		parts := strings.SplitN(env, "=", 2)
@@ -56,16 +197,43 @@ func Load() (*Config, error) {
 			// Extract the placeholder name (everything after STAGE_)
 			placeholder := strings.TrimPrefix(key, "STAGE_")
 
+			// STAGE_PROFILE selects a profile and is not a value
+			if key == "STAGE_PROFILE" {
+				continue
+			}
+
 			// Validate placeholder name
 			if placeholder == "" || strings.TrimSpace(placeholder) == "" {
 				slog.Warn("Ignoring invalid STAGE_ variable with empty name", "key", key)
 				continue
 			}
 
+			// STAGE_<NAME>_FILE names a file holding the value for NAME
+			if name, ok := strings.CutSuffix(placeholder, fileSuffix); ok && name != "" {
+				valueFiles[name] = value
+				continue
+			}
+
 			cfg.Replacements[placeholder] = value
 		}
 	}
 
+	if value := os.Getenv("CACHE_CONTROL_RULES"); value != "" {
+		cacheRules, err := parseCacheRules(value)
+		if err != nil {
+			return nil, err
+		}
+		cfg.CacheRules = cacheRules
+	}
+
+	if value := os.Getenv("REQUEST_VALUES"); value != "" {
+		requestValues, err := parseRequestValues(value)
+		if err != nil {
+			return nil, err
+		}
+		cfg.RequestValues = requestValues
+	}
+
 	// Special case: if FM_KEY is set, also add it to replacements
 	// This allows users to set FM_KEY once for both stage's use and for transformations
 	if cfg.FMKey != "" {
@@ -77,33 +245,150 @@ func Load() (*Config, error) {
 		return nil, err
 	}
 
+	if err := cfg.loadValueFiles(valueFiles); err != nil {
+		return nil, err
+	}
+
+	// Hosts layer their replacements over the complete top-level set
+	cfg.layerHosts()
+
 	return cfg, nil
 }
 
-// Validate checks if the configuration is valid
+// Validate checks if the configuration is valid. Errors in settings read
+// from a config file are prefixed with its name and line.
 func (c *Config) Validate() error {
 	if c.Port == "" {
-		return fmt.Errorf("PORT cannot be empty")
+		return c.errorf("PORT", "PORT cannot be empty")
 	}
 
 	// Validate port is a number in valid range
 	portNum, err := strconv.Atoi(c.Port)
 	if err != nil || portNum < 1 || portNum > 65535 {
-		return fmt.Errorf("PORT must be a number between 1 and 65535, got: %s", c.Port)
+		return c.errorf("PORT", "PORT must be a number between 1 and 65535, got: %s", c.Port)
 	}
 
 	if c.AssetDir == "" {
-		return fmt.Errorf("ASSET_DIR cannot be empty")
+		return c.errorf("ASSET_DIR", "ASSET_DIR cannot be empty")
+	}
+
+	if err := validatePlaceholderPattern(c.PlaceholderPattern); err != nil {
+		return c.errorf("PLACEHOLDER_PATTERN", "PLACEHOLDER_PATTERN %w, got: %q", err, c.PlaceholderPattern)
+	}
+
+	switch c.FileValueTrim {
+	case "", TrimNewline, TrimSpace, TrimNone:
+	default:
+		return c.errorf("FILE_VALUE_TRIM", "FILE_VALUE_TRIM must be %q, %q or %q, got: %s", TrimNewline, TrimSpace, TrimNone, c.FileValueTrim)
+	}
+
+	if c.FileValueMaxBytes < 0 {
+		return c.errorf("FILE_VALUE_MAX_BYTES", "FILE_VALUE_MAX_BYTES cannot be negative, got: %d", c.FileValueMaxBytes)
+	}
+
+	if c.CacheMaxMB < 0 {
+		return c.errorf("CACHE_MAX_MB", "CACHE_MAX_MB cannot be negative, got: %d", c.CacheMaxMB)
+	}
+
+	if c.CSPNonce && !strings.Contains(c.CSPPolicy, "{nonce}") {
+		return c.errorf("CSP_POLICY", "CSP_POLICY must contain {nonce} where the nonce goes, got: %q", c.CSPPolicy)
 	}
 
 	// Check if asset directory exists
 	if _, err := os.Stat(c.AssetDir); os.IsNotExist(err) {
-		return fmt.Errorf("asset directory does not exist: %s", c.AssetDir)
+		return c.errorf("ASSET_DIR", "asset directory does not exist: %s", c.AssetDir)
+	}
+
+	// Reject malformed glob patterns up front rather than silently never matching
+	for i, pattern := range c.TransformInclude {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return c.errorf(c.itemKey("TRANSFORM_INCLUDE", i), "invalid transform pattern %q: %w", pattern, err)
+		}
+	}
+	for i, pattern := range c.TransformExclude {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return c.errorf(c.itemKey("TRANSFORM_EXCLUDE", i), "invalid transform pattern %q: %w", pattern, err)
+		}
 	}
 
+	for i, rule := range c.CacheRules {
+		key := c.itemKey("CACHE_CONTROL_RULES", i)
+		if _, err := path.Match(rule.Pattern, ""); err != nil {
+			return c.errorf(key, "invalid cache rule pattern %q: %w", rule.Pattern, err)
+		}
+		if strings.TrimSpace(rule.CacheControl) == "" {
+			return c.errorf(key, "cache rule %q has an empty Cache-Control value", rule.Pattern)
+		}
+	}
+
+	if err := c.validateRequestValues(); err != nil {
+		return err
+	}
+
+	if err := c.validateHosts(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Delimiters returns the text before and after NAME in PlaceholderPattern.
+// An empty pattern gives the default "__" delimiters.
+func (c *Config) Delimiters() (open, close string) {
+	if c.PlaceholderPattern == "" {
+		return "__", "__"
+	}
+	open, close, _ = strings.Cut(c.PlaceholderPattern, "NAME")
+	return open, close
+}
+
+// validatePlaceholderPattern checks that a placeholder pattern has NAME once,
+// between non-empty delimiters the engine can tell apart from modifiers and
+// filters. An empty pattern means the default.
+func validatePlaceholderPattern(pattern string) error {
+	if pattern == "" {
+		return nil
+	}
+	if strings.Count(pattern, "NAME") != 1 {
+		return fmt.Errorf("must contain NAME exactly once")
+	}
+	open, close, _ := strings.Cut(pattern, "NAME")
+	if open == "" || close == "" {
+		return fmt.Errorf("needs text before and after NAME")
+	}
+	if strings.ContainsAny(pattern, "\r\n") {
+		return fmt.Errorf("cannot contain line breaks")
+	}
+	if strings.HasPrefix(close, ":") || strings.HasPrefix(close, "|") {
+		return fmt.Errorf("cannot close with ':' or '|', which start modifiers and filters")
+	}
 	return nil
 }
 
+// parseCacheRules parses CACHE_CONTROL_RULES, a semicolon-separated list of
+// pattern=directives pairs, e.g. "*.html=no-cache;static/**=public, max-age=86400".
+// Semicolons separate rules because Cache-Control directives contain commas.
+func parseCacheRules(value string) ([]CacheRule, error) {
+	var rules []CacheRule
+	for _, item := range strings.Split(value, ";") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		pattern, directives, found := strings.Cut(item, "=")
+		if !found || strings.TrimSpace(pattern) == "" {
+			return nil, fmt.Errorf("invalid CACHE_CONTROL_RULES entry %q, expected pattern=directives", item)
+		}
+
+		rules = append(rules, CacheRule{
+			Pattern:      strings.TrimSpace(pattern),
+			CacheControl: strings.TrimSpace(directives),
+		})
+	}
+	return rules, nil
+}
+
 // getEnvOrDefault retrieves an environment variable or returns a default value
 func getEnvOrDefault(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
@@ -129,3 +414,58 @@ func getBoolEnvOrDefault(key string, defaultValue bool) bool {
 		return defaultValue
 	}
 }
+
+// getDurationEnvOrDefault retrieves a duration environment variable (e.g. "500ms")
+// or returns a default value if it is unset or invalid
+func getDurationEnvOrDefault(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration < 0 {
+		slog.Warn("Ignoring invalid duration", "key", key, "value", value)
+		return defaultValue
+	}
+
+	return duration
+}
+
+// getIntEnvOrDefault retrieves a non-negative integer environment variable
+// or returns a default value
+func getIntEnvOrDefault(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		slog.Warn("Ignoring invalid number", "key", key, "value", value)
+		return defaultValue
+	}
+
+	return n
+}
+
+// getListEnvOrDefault retrieves a comma-separated environment variable as a
+// list or returns a default value if it is unset
+func getListEnvOrDefault(key string, defaultValue []string) []string {
+	if os.Getenv(key) == "" {
+		return defaultValue
+	}
+	return getListEnv(key)
+}
+
+// getListEnv retrieves a comma-separated environment variable as a list,
+// dropping empty items
+func getListEnv(key string) []string {
+	var result []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
@@ -2,13 +2,20 @@ package config
 
 import (
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestLoad(t *testing.T) {
 	// Create a temporary directory for testing
 	tempDir := t.TempDir()
 
+	secretFile := filepath.Join(t.TempDir(), "ff-sdk-key")
+	if err := os.WriteFile(secretFile, []byte("file-key-123\n"), 0600); err != nil {
+		t.Fatalf("failed to create secret file: %v", err)
+	}
+
 	tests := []struct {
 		name          string
 		envVars       map[string]string
@@ -90,6 +97,39 @@ func TestLoad(t *testing.T) {
 				"FF_SDK_KEY": "test-key-123",
 			},
 		},
+		{
+			name: "value read from STAGE_ file variable",
+			envVars: map[string]string{
+				"ASSET_DIR":             tempDir,
+				"STAGE_FF_SDK_KEY_FILE": secretFile,
+				"STAGE_APP_NAME":        "Test App",
+			},
+			expectError:  false,
+			expectedPort: "8080",
+			expectedHost: "0.0.0.0",
+			expectedDir:  tempDir,
+			expectedReplacements: map[string]string{
+				"FF_SDK_KEY": "file-key-123",
+				"APP_NAME":   "Test App",
+			},
+		},
+		{
+			name: "missing value file",
+			envVars: map[string]string{
+				"ASSET_DIR":             tempDir,
+				"STAGE_FF_SDK_KEY_FILE": filepath.Join(tempDir, "missing"),
+			},
+			expectError: true,
+		},
+		{
+			name: "value set directly and from file",
+			envVars: map[string]string{
+				"ASSET_DIR":             tempDir,
+				"STAGE_FF_SDK_KEY":      "env-key",
+				"STAGE_FF_SDK_KEY_FILE": secretFile,
+			},
+			expectError: true,
+		},
 		{
 			name: "missing asset directory",
 			envVars: map[string]string{
@@ -276,6 +316,85 @@ func TestValidate(t *testing.T) {
 			},
 			expectError: true,
 		},
+		{
+			name: "valid transform patterns",
+			config: &Config{
+				Port:             "8080",
+				AssetDir:         tempDir,
+				Host:             "0.0.0.0",
+				Replacements:     map[string]string{},
+				TransformInclude: []string{"*.map", "config/**"},
+				TransformExclude: []string{"vendor/**/*.js"},
+			},
+			expectError: false,
+		},
+		{
+			name: "invalid transform pattern",
+			config: &Config{
+				Port:             "8080",
+				AssetDir:         tempDir,
+				Host:             "0.0.0.0",
+				Replacements:     map[string]string{},
+				TransformExclude: []string{"vendor/[.js"},
+			},
+			expectError: true,
+		},
+		{
+			name: "valid file value trim",
+			config: &Config{
+				Port:          "8080",
+				AssetDir:      tempDir,
+				Host:          "0.0.0.0",
+				Replacements:  map[string]string{},
+				FileValueTrim: TrimSpace,
+			},
+			expectError: false,
+		},
+		{
+			name: "invalid file value trim",
+			config: &Config{
+				Port:          "8080",
+				AssetDir:      tempDir,
+				Host:          "0.0.0.0",
+				Replacements:  map[string]string{},
+				FileValueTrim: "all",
+			},
+			expectError: true,
+		},
+		{
+			name: "empty cache rule value",
+			config: &Config{
+				Port:         "8080",
+				AssetDir:     tempDir,
+				Host:         "0.0.0.0",
+				Replacements: map[string]string{},
+				CacheRules:   []CacheRule{{Pattern: "*.html", CacheControl: ""}},
+			},
+			expectError: true,
+		},
+		{
+			name: "CSP policy without nonce",
+			config: &Config{
+				Port:         "8080",
+				AssetDir:     tempDir,
+				Host:         "0.0.0.0",
+				Replacements: map[string]string{},
+				CSPNonce:     true,
+				CSPPolicy:    "default-src 'self'",
+			},
+			expectError: true,
+		},
+		{
+			name: "CSP policy ignored without nonces",
+			config: &Config{
+				Port:         "8080",
+				AssetDir:     tempDir,
+				Host:         "0.0.0.0",
+				Replacements: map[string]string{},
+				CSPPolicy:    "default-src 'self'",
+			},
+			expectError: false,
+		},
 		{
 			name: "nonexistent asset dir",
 			config: &Config{
@@ -303,6 +422,55 @@ func TestValidate(t *testing.T) {
 	}
 }
 
+func TestValidatePlaceholderPattern(t *testing.T) {
+	tests := []struct {
+		pattern     string
+		expectError bool
+	}{
+		{"", false},
+		{"__NAME__", false},
+		{"${NAME}", false},
+		{"{{NAME}}", false},
+		{"%%NAME%%", false},
+		{"NAME", true},
+		{"${NAME", true},
+		{"NAME}", true},
+		{"{{NAME}}NAME", true},
+		{"{{ NAME }}", false},
+		{"{{\nNAME}}", true},
+		{"{{NAME:}}", true},
+		{"[NAME|]", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			err := validatePlaceholderPattern(tt.pattern)
+			if (err != nil) != tt.expectError {
+				t.Errorf("expected error %v, got %v", tt.expectError, err)
+			}
+		})
+	}
+}
+
+func TestDelimiters(t *testing.T) {
+	tests := []struct {
+		pattern     string
+		open, close string
+	}{
+		{"", "__", "__"},
+		{"__NAME__", "__", "__"},
+		{"${NAME}", "${", "}"},
+		{"<%= NAME %>", "<%= ", " %>"},
+	}
+
+	for _, tt := range tests {
+		cfg := &Config{PlaceholderPattern: tt.pattern}
+		if open, close := cfg.Delimiters(); open != tt.open || close != tt.close {
+			t.Errorf("%q: expected %q and %q, got %q and %q", tt.pattern, tt.open, tt.close, open, close)
+		}
+	}
+}
+
 func TestGetEnvOrDefault(t *testing.T) {
 	tests := []struct {
 		name         string
@@ -357,12 +525,175 @@ func TestGetEnvOrDefault(t *testing.T) {
 	}
 }
 
+func TestParseCacheRules(t *testing.T) {
+	tests := []struct {
+		name        string
+		value       string
+		expected    []CacheRule
+		expectError bool
+	}{
+		{name: "empty", value: "", expected: nil},
+		{
+			name:  "single rule",
+			value: "*.html=no-cache",
+			expected: []CacheRule{
+				{Pattern: "*.html", CacheControl: "no-cache"},
+			},
+		},
+		{
+			name:  "directives with commas and equals",
+			value: "*.html=no-cache; static/**=public, max-age=86400 ;",
+			expected: []CacheRule{
+				{Pattern: "*.html", CacheControl: "no-cache"},
+				{Pattern: "static/**", CacheControl: "public, max-age=86400"},
+			},
+		},
+		{name: "missing separator", value: "*.html", expectError: true},
+		{name: "missing pattern", value: "=no-cache", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := parseCacheRules(tt.value)
+
+			if tt.expectError {
+				if err == nil {
+					t.Error("expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(rules) != len(tt.expected) {
+				t.Fatalf("expected %v, got %v", tt.expected, rules)
+			}
+			for i := range tt.expected {
+				if rules[i] != tt.expected[i] {
+					t.Errorf("at %d expected %+v, got %+v", i, tt.expected[i], rules[i])
+				}
+			}
+		})
+	}
+}
+
+func TestGetDurationEnvOrDefault(t *testing.T) {
+	tests := []struct {
+		name     string
+		envValue string
+		expected time.Duration
+	}{
+		{"unset", "", time.Second},
+		{"milliseconds", "250ms", 250 * time.Millisecond},
+		{"seconds", "2s", 2 * time.Second},
+		{"invalid", "soon", time.Second},
+		{"negative", "-1s", time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Setenv("TEST_DURATION", tt.envValue)
+			defer os.Unsetenv("TEST_DURATION")
+
+			result := getDurationEnvOrDefault("TEST_DURATION", time.Second)
+			if result != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestGetIntEnvOrDefault(t *testing.T) {
+	tests := []struct {
+		name     string
+		envValue string
+		expected int
+	}{
+		{"unset", "", 7},
+		{"zero", "0", 0},
+		{"number", "256", 256},
+		{"invalid", "lots", 7},
+		{"negative", "-5", 7},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Setenv("TEST_INT", tt.envValue)
+			defer os.Unsetenv("TEST_INT")
+
+			result := getIntEnvOrDefault("TEST_INT", 7)
+			if result != tt.expected {
+				t.Errorf("expected %d, got %d", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestGetListEnv(t *testing.T) {
+	tests := []struct {
+		name     string
+		envValue string
+		expected []string
+	}{
+		{"unset", "", nil},
+		{"single item", "DEV", []string{"DEV"}},
+		{"multiple items with spaces", "DEV, NEXT_DATA ,CUSTOM", []string{"DEV", "NEXT_DATA", "CUSTOM"}},
+		{"empty items dropped", "DEV,,", []string{"DEV"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Setenv("TEST_LIST", tt.envValue)
+			defer os.Unsetenv("TEST_LIST")
+
+			result := getListEnv("TEST_LIST")
+
+			if len(result) != len(tt.expected) {
+				t.Fatalf("expected %v, got %v", tt.expected, result)
+			}
+			for i := range tt.expected {
+				if result[i] != tt.expected[i] {
+					t.Errorf("at %d expected %s, got %s", i, tt.expected[i], result[i])
+				}
+			}
+		})
+	}
+}
+
+func TestLoadReloadToken(t *testing.T) {
+	clearEnv()
+	defer clearEnv()
+	os.Setenv("ASSET_DIR", t.TempDir())
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.ReloadToken != "" {
+		t.Errorf("expected no reload token by default, got %q", cfg.ReloadToken)
+	}
+
+	os.Setenv("RELOAD_TOKEN", "s3cret")
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.ReloadToken != "s3cret" {
+		t.Errorf("expected reload token from RELOAD_TOKEN, got %q", cfg.ReloadToken)
+	}
+	if _, ok := cfg.Replacements["RELOAD_TOKEN"]; ok {
+		t.Error("expected the reload token not to be a replacement")
+	}
+}
+
 // clearEnv removes all test-related environment variables
 func clearEnv() {
 	testVars := []string{
 		"PORT", "HOST", "ASSET_DIR",
 		"STAGE_FF_SDK_KEY", "STAGE_API_ENDPOINT", "STAGE_APP_NAME",
-		"REGULAR_VAR",
+		"STAGE_FF_SDK_KEY_FILE", "STAGE_PROFILE", "CSP_NONCE", "CSP_POLICY", "REQUEST_VALUES",
+		"RELOAD_TOKEN", "REGULAR_VAR",
 	}
 	for _, v := range testVars {
 		os.Unsetenv(v)
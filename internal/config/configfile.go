@@ -0,0 +1,482 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-yaml"
+	"github.com/goccy/go-yaml/ast"
+	"github.com/goccy/go-yaml/parser"
+	"github.com/pelletier/go-toml/v2"
+	"github.com/pelletier/go-toml/v2/unstable"
+)
+
+// fileConfig is the schema of a stage.yaml or stage.toml config file.
+// Pointers and nil lists tell settings left out of the file from zero values.
+type fileConfig struct {
+	Port     *int    `yaml:"port" toml:"port"`
+	Host     *string `yaml:"host" toml:"host"`
+	AssetDir *string `yaml:"asset_dir" toml:"asset_dir"`
+
+	Prometheus struct {
+		Enabled  *bool   `yaml:"enabled" toml:"enabled"`
+		Scenario *string `yaml:"scenario" toml:"scenario"`
+	} `yaml:"prometheus" toml:"prometheus"`
+
+	Replacements map[string]fileValue `yaml:"replacements" toml:"replacements"`
+
+	// Profiles are selected with STAGE_PROFILE
+	Profiles map[string]fileProfile `yaml:"profiles" toml:"profiles"`
+
+	// Hosts are selected by the Host header of each request
+	Hosts map[string]fileHost `yaml:"hosts" toml:"hosts"`
+
+	Placeholders struct {
+		Pattern *string  `yaml:"pattern" toml:"pattern"`
+		Strict  *bool    `yaml:"strict" toml:"strict"`
+		Ignore  []string `yaml:"ignore" toml:"ignore"`
+		Escape  *bool    `yaml:"escape" toml:"escape"`
+		Raw     []string `yaml:"raw" toml:"raw"`
+	} `yaml:"placeholders" toml:"placeholders"`
+
+	Transform struct {
+		Include []string `yaml:"include" toml:"include"`
+		Exclude []string `yaml:"exclude" toml:"exclude"`
+		Sniff   *bool    `yaml:"sniff" toml:"sniff"`
+	} `yaml:"transform" toml:"transform"`
+
+	Compression *bool `yaml:"compression" toml:"compression"`
+
+	Cache struct {
+		MaxMB *int            `yaml:"max_mb" toml:"max_mb"`
+		Rules []fileCacheRule `yaml:"rules" toml:"rules"`
+	} `yaml:"cache" toml:"cache"`
+
+	RuntimeEnv struct {
+		Allowlist []string `yaml:"allowlist" toml:"allowlist"`
+	} `yaml:"runtime_env" toml:"runtime_env"`
+
+	HotReload struct {
+		Enabled  *bool   `yaml:"enabled" toml:"enabled"`
+		Debounce *string `yaml:"debounce" toml:"debounce"`
+	} `yaml:"hot_reload" toml:"hot_reload"`
+
+	CSP struct {
+		Nonce  *bool   `yaml:"nonce" toml:"nonce"`
+		Policy *string `yaml:"policy" toml:"policy"`
+	} `yaml:"csp" toml:"csp"`
+
+	// RequestValues map placeholder names to "source[:key]"
+	RequestValues map[string]string `yaml:"request_values" toml:"request_values"`
+
+	ValueFiles struct {
+		Trim     *string `yaml:"trim" toml:"trim"`
+		MaxBytes *int    `yaml:"max_bytes" toml:"max_bytes"`
+	} `yaml:"value_files" toml:"value_files"`
+}
+
+// fileProfile is a named set of replacements layered over the base ones
+type fileProfile struct {
+	Replacements map[string]fileValue `yaml:"replacements" toml:"replacements"`
+}
+
+// fileCacheRule is a cache.rules entry
+type fileCacheRule struct {
+	Pattern      string `yaml:"pattern" toml:"pattern"`
+	CacheControl string `yaml:"cache_control" toml:"cache_control"`
+}
+
+// fileKeys maps config file keys to the environment variables that
+// override them; validation errors name the variable
+var fileKeys = map[string]string{
+	"port":                  "PORT",
+	"host":                  "HOST",
+	"asset_dir":             "ASSET_DIR",
+	"prometheus.enabled":    "PROMETHEUS_ENABLED",
+	"prometheus.scenario":   "STAGE_PROMETHEUS_SCENARIO",
+	"placeholders.pattern":  "PLACEHOLDER_PATTERN",
+	"placeholders.strict":   "STRICT_PLACEHOLDERS",
+	"placeholders.ignore":   "IGNORE_PLACEHOLDERS",
+	"placeholders.escape":   "ESCAPE_VALUES",
+	"placeholders.raw":      "RAW_PLACEHOLDERS",
+	"transform.include":     "TRANSFORM_INCLUDE",
+	"transform.exclude":     "TRANSFORM_EXCLUDE",
+	"transform.sniff":       "TRANSFORM_SNIFF",
+	"compression":           "COMPRESSION",
+	"cache.max_mb":          "CACHE_MAX_MB",
+	"cache.rules":           "CACHE_CONTROL_RULES",
+	"runtime_env.allowlist": "RUNTIME_ENV_ALLOWLIST",
+	"hot_reload.enabled":    "HOT_RELOAD",
+	"hot_reload.debounce":   "HOT_RELOAD_DEBOUNCE",
+	"csp.nonce":             "CSP_NONCE",
+	"csp.policy":            "CSP_POLICY",
+	"request_values":        "REQUEST_VALUES",
+	"value_files.trim":      "FILE_VALUE_TRIM",
+	"value_files.max_bytes": "FILE_VALUE_MAX_BYTES",
+}
+
+// fileValue is a replacement value from the config file. Unquoted scalars
+// keep their literal text, so "version: 1.20" is "1.20" and not "1.2".
+type fileValue string
+
+// UnmarshalYAML implements yaml.BytesUnmarshaler
+func (v *fileValue) UnmarshalYAML(data []byte) error {
+	text := strings.TrimSpace(string(data))
+	switch {
+	case text == "" || text == "null" || text == "~":
+		*v = ""
+	case strings.ContainsAny(text[:1], `"'|>`):
+		var s string
+		if err := yaml.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		*v = fileValue(s)
+	case strings.ContainsAny(text[:1], "[{"):
+		return fmt.Errorf("replacement values must be strings, numbers or booleans")
+	default:
+		*v = fileValue(text)
+	}
+	return nil
+}
+
+// UnmarshalTOML implements unstable.Unmarshaler
+func (v *fileValue) UnmarshalTOML(node *unstable.Node) error {
+	switch node.Kind {
+	case unstable.String, unstable.Bool, unstable.Integer, unstable.Float,
+		unstable.LocalDate, unstable.LocalTime, unstable.LocalDateTime, unstable.DateTime:
+		*v = fileValue(node.Data)
+		return nil
+	default:
+		return fmt.Errorf("replacement values must be strings, numbers or booleans")
+	}
+}
+
+// loadFile applies the settings of the config file at path to c, including
+// the replacements of profile if not empty, recording the line each came from
+func (c *Config) loadFile(path, profile string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("cannot read config file: %w", err)
+	}
+
+	var fc fileConfig
+	var lines map[string]int
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		if err := yaml.UnmarshalWithOptions(data, &fc, yaml.DisallowUnknownField()); err != nil {
+			return fileError(path, err)
+		}
+		if lines, err = yamlLines(data); err != nil {
+			return fileError(path, err)
+		}
+	case ".toml":
+		dec := toml.NewDecoder(bytes.NewReader(data)).DisallowUnknownFields().EnableUnmarshalerInterface()
+		if err := dec.Decode(&fc); err != nil {
+			return fileError(path, err)
+		}
+		lines = tomlLines(data)
+	default:
+		return fmt.Errorf("config file %s must end in .yaml, .yml or .toml", path)
+	}
+
+	c.File = path
+	c.origins = make(map[string]int)
+	for key, env := range fileKeys {
+		if line, ok := lines[key]; ok {
+			c.origins[env] = line
+		}
+	}
+
+	// Hosts are named by the file alone
+	for name := range fc.Hosts {
+		if line, ok := lines[hostKey(name)]; ok {
+			c.origins[hostKey(strings.ToLower(strings.TrimSpace(name)))] = line
+		}
+	}
+
+	// List items get their own line where the format allows it
+	items := map[string]int{
+		"transform.include": len(fc.Transform.Include),
+		"transform.exclude": len(fc.Transform.Exclude),
+		"cache.rules":       len(fc.Cache.Rules),
+	}
+	for key, n := range items {
+		for i := 0; i < n; i++ {
+			item := fmt.Sprintf("%s[%d]", key, i)
+			if line, ok := lines[item]; ok {
+				c.origins[fmt.Sprintf("%s[%d]", fileKeys[key], i)] = line
+			}
+		}
+	}
+
+	return c.applyFile(path, &fc, profile)
+}
+
+// applyFile copies the settings present in fc over the defaults in c
+func (c *Config) applyFile(path string, fc *fileConfig, profile string) error {
+	if fc.Port != nil {
+		c.Port = fmt.Sprint(*fc.Port)
+	}
+	setString(&c.Host, fc.Host)
+	setString(&c.AssetDir, fc.AssetDir)
+	setBool(&c.PrometheusEnabled, fc.Prometheus.Enabled)
+	setString(&c.PrometheusScenario, fc.Prometheus.Scenario)
+
+	if err := c.setReplacements(path, fc.Replacements); err != nil {
+		return err
+	}
+	if profile != "" {
+		p, ok := fc.Profiles[profile]
+		if !ok {
+			return fmt.Errorf("%s: profile %q selected by STAGE_PROFILE is not defined, available: %s",
+				path, profile, strings.Join(profileNames(fc.Profiles), ", "))
+		}
+		if err := c.setReplacements(path, p.Replacements); err != nil {
+			return err
+		}
+	}
+
+	if fc.Hosts != nil {
+		if err := c.setHosts(path, fc.Hosts, fc.Profiles); err != nil {
+			return err
+		}
+	}
+
+	setString(&c.PlaceholderPattern, fc.Placeholders.Pattern)
+	setBool(&c.StrictPlaceholders, fc.Placeholders.Strict)
+	setList(&c.IgnorePlaceholders, fc.Placeholders.Ignore)
+	setBool(&c.EscapeValues, fc.Placeholders.Escape)
+	setList(&c.RawPlaceholders, fc.Placeholders.Raw)
+
+	setList(&c.TransformInclude, fc.Transform.Include)
+	setList(&c.TransformExclude, fc.Transform.Exclude)
+	setBool(&c.TransformSniff, fc.Transform.Sniff)
+
+	setBool(&c.Compression, fc.Compression)
+
+	setInt(&c.CacheMaxMB, fc.Cache.MaxMB)
+	for _, rule := range fc.Cache.Rules {
+		c.CacheRules = append(c.CacheRules, CacheRule{
+			Pattern:      strings.TrimSpace(rule.Pattern),
+			CacheControl: strings.TrimSpace(rule.CacheControl),
+		})
+	}
+
+	setList(&c.RuntimeEnvAllowlist, fc.RuntimeEnv.Allowlist)
+
+	setBool(&c.HotReload, fc.HotReload.Enabled)
+	if fc.HotReload.Debounce != nil {
+		duration, err := time.ParseDuration(*fc.HotReload.Debounce)
+		if err != nil || duration < 0 {
+			return c.errorf("HOT_RELOAD_DEBOUNCE", "hot_reload.debounce must be a duration such as \"250ms\", got: %s", *fc.HotReload.Debounce)
+		}
+		c.HotReloadDebounce = duration
+	}
+
+	setBool(&c.CSPNonce, fc.CSP.Nonce)
+	setString(&c.CSPPolicy, fc.CSP.Policy)
+
+	if fc.RequestValues != nil {
+		c.RequestValues = fileRequestValues(fc.RequestValues)
+	}
+
+	setString(&c.FileValueTrim, fc.ValueFiles.Trim)
+	setInt(&c.FileValueMaxBytes, fc.ValueFiles.MaxBytes)
+
+	return nil
+}
+
+// setReplacements copies replacement values from the config file at path
+func (c *Config) setReplacements(path string, values map[string]fileValue) error {
+	return setValues(path, c.Replacements, values)
+}
+
+// profileNames returns the names of the profiles in order
+func profileNames(profiles map[string]fileProfile) []string {
+	if len(profiles) == 0 {
+		return []string{"none"}
+	}
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// dropOverriddenOrigins forgets the file lines of settings overridden by
+// environment variables, so their errors don't point at the file
+func (c *Config) dropOverriddenOrigins() {
+	for key := range c.origins {
+		env, _, _ := strings.Cut(key, "[")
+		if os.Getenv(env) != "" {
+			delete(c.origins, key)
+		}
+	}
+}
+
+// errorf returns a validation error for the setting of the environment
+// variable key, prefixed with the config file line it came from, if any
+func (c *Config) errorf(key, format string, args ...any) error {
+	err := fmt.Errorf(format, args...)
+	if line, ok := c.origins[key]; ok {
+		return fmt.Errorf("%s:%d: %w", c.File, line, err)
+	}
+	return err
+}
+
+// itemKey returns the origin key of item i of a list setting, falling back
+// to the whole setting when the item has no line of its own
+func (c *Config) itemKey(key string, i int) string {
+	item := fmt.Sprintf("%s[%d]", key, i)
+	if _, ok := c.origins[item]; ok {
+		return item
+	}
+	return key
+}
+
+// fileError adds the position of a parse or type error to its message
+func fileError(path string, err error) error {
+	var yamlErr yaml.Error
+	if errors.As(err, &yamlErr) && yamlErr.GetToken() != nil {
+		pos := yamlErr.GetToken().Position
+		return fmt.Errorf("%s:%d:%d: %s", path, pos.Line, pos.Column, yamlErr.GetMessage())
+	}
+
+	var decodeErr *toml.DecodeError
+	if errors.As(err, &decodeErr) {
+		row, col := decodeErr.Position()
+		return fmt.Errorf("%s:%d:%d: %s", path, row, col, strings.TrimPrefix(decodeErr.Error(), "toml: "))
+	}
+
+	var strictErr *toml.StrictMissingError
+	if errors.As(err, &strictErr) && len(strictErr.Errors) > 0 {
+		unknown := strictErr.Errors[0]
+		row, col := unknown.Position()
+		return fmt.Errorf("%s:%d:%d: unknown setting %q", path, row, col, strings.Join(unknown.Key(), "."))
+	}
+
+	return fmt.Errorf("%s: %w", path, err)
+}
+
+// yamlLines maps each dotted key of a YAML document, and each list item as
+// key[i], to its line
+func yamlLines(data []byte) (map[string]int, error) {
+	file, err := parser.ParseBytes(data, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := make(map[string]int)
+	for _, doc := range file.Docs {
+		walkYAML(doc.Body, "", lines)
+	}
+	return lines, nil
+}
+
+func walkYAML(node ast.Node, prefix string, lines map[string]int) {
+	switch n := node.(type) {
+	case *ast.MappingNode:
+		for _, value := range n.Values {
+			walkYAML(value, prefix, lines)
+		}
+	case *ast.MappingValueNode:
+		key := joinKey(prefix, n.Key.GetToken().Value)
+		lines[key] = n.Key.GetToken().Position.Line
+		walkYAML(n.Value, key, lines)
+	case *ast.SequenceNode:
+		for i, value := range n.Values {
+			item := fmt.Sprintf("%s[%d]", prefix, i)
+			lines[item] = value.GetToken().Position.Line
+			walkYAML(value, item, lines)
+		}
+	}
+}
+
+// tomlLines maps each dotted key of a TOML document, and each [[array]]
+// table as key[i], to its line. Items of inline arrays share the line of
+// their key.
+func tomlLines(data []byte) map[string]int {
+	lines := make(map[string]int)
+	arrays := make(map[string]int) // [[array]] tables seen so far
+	table := ""
+
+	for i, line := range strings.Split(string(data), "\n") {
+		n := i + 1
+		line = strings.TrimSpace(line)
+
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+		case strings.HasPrefix(line, "[["):
+			name, _, _ := strings.Cut(line[2:], "]]")
+			name = tomlKey(name)
+			if _, ok := lines[name]; !ok {
+				lines[name] = n
+			}
+			table = fmt.Sprintf("%s[%d]", name, arrays[name])
+			arrays[name]++
+			lines[table] = n
+		case strings.HasPrefix(line, "["):
+			name, _, _ := strings.Cut(line[1:], "]")
+			table = tomlKey(name)
+			lines[table] = n
+		default:
+			key, _, ok := strings.Cut(line, "=")
+			if !ok {
+				continue
+			}
+			full := joinKey(table, tomlKey(key))
+			if _, seen := lines[full]; !seen {
+				lines[full] = n
+			}
+		}
+	}
+
+	return lines
+}
+
+// tomlKey normalizes a possibly quoted, dotted TOML key
+func tomlKey(key string) string {
+	parts := strings.Split(key, ".")
+	for i, part := range parts {
+		parts[i] = strings.Trim(strings.TrimSpace(part), `"'`)
+	}
+	return strings.Join(parts, ".")
+}
+
+func joinKey(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
+
+func setString(dst *string, value *string) {
+	if value != nil {
+		*dst = *value
+	}
+}
+
+func setBool(dst *bool, value *bool) {
+	if value != nil {
+		*dst = *value
+	}
+}
+
+func setInt(dst *int, value *int) {
+	if value != nil {
+		*dst = *value
+	}
+}
+
+func setList(dst *[]string, value []string) {
+	if value != nil {
+		*dst = value
+	}
+}
@@ -0,0 +1,482 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeConfigFile writes a config file named name into a new temporary
+// directory, replacing ASSET_DIR in content with that directory
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	dir := t.TempDir()
+	path := filepath.Join(dir, name)
+	content = strings.ReplaceAll(content, "ASSET_DIR", filepath.ToSlash(dir))
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+	return path
+}
+
+const yamlConfig = `port: 9000
+asset_dir: ASSET_DIR
+prometheus:
+  enabled: false
+replacements:
+  API_URL: "https://api.example.com"
+  VERSION: 1.20
+  DEBUG: true
+  BANNER: |
+    line one
+    line two
+placeholders:
+  strict: true
+  ignore: [LEGACY]
+transform:
+  include:
+    - "*.map"
+  sniff: true
+compression: false
+cache:
+  max_mb: 64
+  rules:
+    - pattern: "*.html"
+      cache_control: no-cache
+runtime_env:
+  allowlist: ["PUBLIC_*"]
+hot_reload:
+  enabled: true
+  debounce: 1s
+csp:
+  nonce: true
+  policy: "script-src 'nonce-{nonce}'"
+value_files:
+  trim: space
+`
+
+const tomlConfig = `port = 9000
+asset_dir = "ASSET_DIR"
+compression = false
+
+[prometheus]
+enabled = false
+
+[replacements]
+API_URL = "https://api.example.com"
+VERSION = 1.20
+DEBUG = true
+BANNER = """
+line one
+line two
+"""
+
+[placeholders]
+strict = true
+ignore = ["LEGACY"]
+
+[transform]
+include = ["*.map"]
+sniff = true
+
+[cache]
+max_mb = 64
+
+[[cache.rules]]
+pattern = "*.html"
+cache_control = "no-cache"
+
+[runtime_env]
+allowlist = ["PUBLIC_*"]
+
+[hot_reload]
+enabled = true
+debounce = "1s"
+
+[csp]
+nonce = true
+policy = "script-src 'nonce-{nonce}'"
+
+[value_files]
+trim = "space"
+`
+
+func TestLoadFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{"yaml", "stage.yaml", yamlConfig},
+		{"yml", "stage.yml", yamlConfig},
+		{"toml", "stage.toml", tomlConfig},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv()
+			path := writeConfigFile(t, tt.file, tt.content)
+
+			cfg, err := LoadFile(path)
+			if err != nil {
+				t.Fatalf("LoadFile failed: %v", err)
+			}
+
+			if cfg.File != path || cfg.Port != "9000" || cfg.Host != "0.0.0.0" {
+				t.Errorf("unexpected server settings: file=%s port=%s host=%s", cfg.File, cfg.Port, cfg.Host)
+			}
+
+			expectedReplacements := map[string]string{
+				"API_URL": "https://api.example.com",
+				"VERSION": "1.20",
+				"DEBUG":   "true",
+				"BANNER":  "line one\nline two\n",
+			}
+			if !reflect.DeepEqual(cfg.Replacements, expectedReplacements) {
+				t.Errorf("expected replacements %q, got %q", expectedReplacements, cfg.Replacements)
+			}
+
+			if cfg.PrometheusEnabled || cfg.Compression || !cfg.StrictPlaceholders || !cfg.TransformSniff || !cfg.HotReload || !cfg.CSPNonce {
+				t.Errorf("unexpected boolean settings: %+v", cfg)
+			}
+			if !cfg.EscapeValues {
+				t.Error("expected settings missing from the file to keep their defaults")
+			}
+			if !reflect.DeepEqual(cfg.IgnorePlaceholders, []string{"LEGACY"}) ||
+				!reflect.DeepEqual(cfg.TransformInclude, []string{"*.map"}) ||
+				!reflect.DeepEqual(cfg.RuntimeEnvAllowlist, []string{"PUBLIC_*"}) {
+				t.Errorf("unexpected lists: %q %q %q", cfg.IgnorePlaceholders, cfg.TransformInclude, cfg.RuntimeEnvAllowlist)
+			}
+			if !reflect.DeepEqual(cfg.CacheRules, []CacheRule{{Pattern: "*.html", CacheControl: "no-cache"}}) {
+				t.Errorf("unexpected cache rules: %+v", cfg.CacheRules)
+			}
+			if cfg.CacheMaxMB != 64 || cfg.HotReloadDebounce != time.Second || cfg.FileValueTrim != TrimSpace {
+				t.Errorf("unexpected settings: max_mb=%d debounce=%v trim=%s", cfg.CacheMaxMB, cfg.HotReloadDebounce, cfg.FileValueTrim)
+			}
+			if cfg.CSPPolicy != "script-src 'nonce-{nonce}'" {
+				t.Errorf("unexpected CSP policy: %s", cfg.CSPPolicy)
+			}
+		})
+	}
+}
+
+func TestLoadFilePrecedence(t *testing.T) {
+	clearEnv()
+	path := writeConfigFile(t, "stage.yaml", `port: 9000
+asset_dir: ASSET_DIR
+compression: false
+replacements:
+  API_URL: from-file
+  APP_NAME: from-file
+  FF_SDK_KEY: from-file
+cache:
+  rules:
+    - pattern: "*.html"
+      cache_control: no-cache
+`)
+
+	secret := filepath.Join(t.TempDir(), "sdk-key")
+	if err := os.WriteFile(secret, []byte("from-secret\n"), 0600); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+
+	t.Setenv("PORT", "9100")
+	t.Setenv("COMPRESSION", "true")
+	t.Setenv("CACHE_CONTROL_RULES", "*.js=public, max-age=60")
+	t.Setenv("STAGE_API_URL", "from-env")
+	t.Setenv("STAGE_FF_SDK_KEY_FILE", secret)
+
+	cfg, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("LoadFile failed: %v", err)
+	}
+
+	if cfg.Port != "9100" || !cfg.Compression {
+		t.Errorf("expected env vars to override the file, got port=%s compression=%v", cfg.Port, cfg.Compression)
+	}
+	if !reflect.DeepEqual(cfg.CacheRules, []CacheRule{{Pattern: "*.js", CacheControl: "public, max-age=60"}}) {
+		t.Errorf("expected CACHE_CONTROL_RULES to replace the file rules, got %+v", cfg.CacheRules)
+	}
+
+	expected := map[string]string{
+		"API_URL":    "from-env",
+		"APP_NAME":   "from-file",
+		"FF_SDK_KEY": "from-secret",
+	}
+	if !reflect.DeepEqual(cfg.Replacements, expected) {
+		t.Errorf("expected replacements %q, got %q", expected, cfg.Replacements)
+	}
+}
+
+func TestLoadFileErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		file        string
+		content     string
+		env         map[string]string
+		expectError string
+	}{
+		{
+			name:        "yaml unknown setting",
+			file:        "stage.yaml",
+			content:     "asset_dir: ASSET_DIR\ncompresion: true\n",
+			expectError: "stage.yaml:2:",
+		},
+		{
+			name:        "yaml wrong type",
+			file:        "stage.yaml",
+			content:     "asset_dir: ASSET_DIR\nport: eighty\n",
+			expectError: "stage.yaml:2:",
+		},
+		{
+			name:        "yaml syntax error",
+			file:        "stage.yaml",
+			content:     "asset_dir: ASSET_DIR\nport: [8080\n",
+			expectError: "stage.yaml:",
+		},
+		{
+			name:        "yaml invalid port",
+			file:        "stage.yaml",
+			content:     "asset_dir: ASSET_DIR\n\nport: 70000\n",
+			expectError: "stage.yaml:3: PORT must be a number between 1 and 65535",
+		},
+		{
+			name:        "yaml CSP policy without nonce",
+			file:        "stage.yaml",
+			content:     "asset_dir: ASSET_DIR\ncsp:\n  nonce: true\n  policy: default-src 'self'\n",
+			expectError: "stage.yaml:4: CSP_POLICY must contain {nonce}",
+		},
+		{
+			name:        "yaml invalid transform pattern",
+			file:        "stage.yaml",
+			content:     "asset_dir: ASSET_DIR\ntransform:\n  include:\n    - \"*.map\"\n    - \"[\"\n",
+			expectError: "stage.yaml:5: invalid transform pattern",
+		},
+		{
+			name:        "yaml empty cache rule",
+			file:        "stage.yaml",
+			content:     "asset_dir: ASSET_DIR\ncache:\n  rules:\n    - pattern: \"*.html\"\n      cache_control: \"\"\n",
+			expectError: "stage.yaml:4: cache rule \"*.html\" has an empty Cache-Control value",
+		},
+		{
+			name:        "yaml invalid debounce",
+			file:        "stage.yaml",
+			content:     "asset_dir: ASSET_DIR\nhot_reload:\n  debounce: soon\n",
+			expectError: "stage.yaml:3: hot_reload.debounce",
+		},
+		{
+			name:        "yaml request value without header name",
+			file:        "stage.yaml",
+			content:     "asset_dir: ASSET_DIR\n\nrequest_values:\n  REGION: header\n",
+			expectError: "stage.yaml:3: request value REGION needs a header name",
+		},
+		{
+			name:        "yaml list replacement",
+			file:        "stage.yaml",
+			content:     "asset_dir: ASSET_DIR\nreplacements:\n  API_URL: [a, b]\n",
+			expectError: "replacement values must be strings, numbers or booleans",
+		},
+		{
+			name:        "missing asset directory",
+			file:        "stage.yaml",
+			content:     "asset_dir: /nonexistent/stage/assets\n",
+			expectError: "stage.yaml:1: asset directory does not exist",
+		},
+		{
+			name:        "overridden setting has no line",
+			file:        "stage.yaml",
+			content:     "asset_dir: ASSET_DIR\nport: 8080\n",
+			env:         map[string]string{"PORT": "0"},
+			expectError: "PORT must be",
+		},
+		{
+			name:        "toml unknown setting",
+			file:        "stage.toml",
+			content:     "asset_dir = \"ASSET_DIR\"\n\n[cache]\nmax_size = 1\n",
+			expectError: "stage.toml:4:1: unknown setting \"cache.max_size\"",
+		},
+		{
+			name:        "toml wrong type",
+			file:        "stage.toml",
+			content:     "asset_dir = \"ASSET_DIR\"\nport = \"eighty\"\n",
+			expectError: "stage.toml:2:",
+		},
+		{
+			name:        "toml invalid transform pattern",
+			file:        "stage.toml",
+			content:     "asset_dir = \"ASSET_DIR\"\n\n[transform]\nexclude = [\"[\"]\n",
+			expectError: "stage.toml:4: invalid transform pattern",
+		},
+		{
+			name:        "toml empty cache rule",
+			file:        "stage.toml",
+			content:     "asset_dir = \"ASSET_DIR\"\n\n[[cache.rules]]\npattern = \"a\"\ncache_control = \"x\"\n\n[[cache.rules]]\npattern = \"b\"\n",
+			expectError: "stage.toml:7: cache rule \"b\" has an empty Cache-Control value",
+		},
+		{
+			name:        "unsupported extension",
+			file:        "stage.json",
+			content:     "{}",
+			expectError: "must end in .yaml, .yml or .toml",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv()
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			path := writeConfigFile(t, tt.file, tt.content)
+
+			_, err := LoadFile(path)
+			if err == nil || !strings.Contains(err.Error(), tt.expectError) {
+				t.Fatalf("expected error containing %q, got %v", tt.expectError, err)
+			}
+			if tt.env != nil && strings.Contains(err.Error(), tt.file) {
+				t.Errorf("expected error for an env var not to point at the file, got %v", err)
+			}
+		})
+	}
+}
+
+func TestLoadFileMissing(t *testing.T) {
+	clearEnv()
+	if _, err := LoadFile(filepath.Join(t.TempDir(), "stage.yaml")); err == nil {
+		t.Fatal("expected error for missing config file")
+	}
+}
+
+func TestLoadUsesConfigFileEnv(t *testing.T) {
+	clearEnv()
+	path := writeConfigFile(t, "stage.yaml", "asset_dir: ASSET_DIR\nport: 9000\n")
+	t.Setenv("CONFIG_FILE", path)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.Port != "9000" {
+		t.Errorf("expected port from CONFIG_FILE, got %s", cfg.Port)
+	}
+}
+
+func TestTomlLines(t *testing.T) {
+	content := `# comment
+port = 8080
+
+[cache]
+max_mb = 1
+
+[[cache.rules]]
+pattern = "a"
+
+[[cache.rules]]
+"pattern" = "b"
+`
+	expected := map[string]int{
+		"port":                   2,
+		"cache":                  4,
+		"cache.max_mb":           5,
+		"cache.rules":            7,
+		"cache.rules[0]":         7,
+		"cache.rules[0].pattern": 8,
+		"cache.rules[1]":         10,
+		"cache.rules[1].pattern": 11,
+	}
+
+	if got := tomlLines([]byte(content)); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestLoadFileProfiles(t *testing.T) {
+	content := `asset_dir: ASSET_DIR
+replacements:
+  APP_NAME: demo
+  API_URL: http://localhost
+profiles:
+  qa:
+    replacements:
+      API_URL: https://qa.example.com
+  prod:
+    replacements:
+      API_URL: https://example.com
+      FF_SDK_KEY: prod-key
+`
+
+	tests := []struct {
+		name        string
+		profile     string
+		env         map[string]string
+		expected    map[string]string
+		expectError string
+	}{
+		{
+			name:     "no profile",
+			expected: map[string]string{"APP_NAME": "demo", "API_URL": "http://localhost"},
+		},
+		{
+			name:     "qa",
+			profile:  "qa",
+			expected: map[string]string{"APP_NAME": "demo", "API_URL": "https://qa.example.com"},
+		},
+		{
+			name:    "env overrides profile",
+			profile: "prod",
+			env:     map[string]string{"STAGE_FF_SDK_KEY": "env-key"},
+			expected: map[string]string{
+				"APP_NAME": "demo", "API_URL": "https://example.com", "FF_SDK_KEY": "env-key",
+			},
+		},
+		{
+			name:        "unknown profile",
+			profile:     "staging",
+			expectError: `profile "staging" selected by STAGE_PROFILE is not defined, available: prod, qa`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv()
+			if tt.profile != "" {
+				t.Setenv("STAGE_PROFILE", tt.profile)
+			}
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			path := writeConfigFile(t, "stage.yaml", content)
+
+			cfg, err := LoadFile(path)
+			if tt.expectError != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectError) {
+					t.Fatalf("expected error containing %q, got %v", tt.expectError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadFile failed: %v", err)
+			}
+
+			if cfg.Profile != tt.profile {
+				t.Errorf("expected profile %q, got %q", tt.profile, cfg.Profile)
+			}
+			if !reflect.DeepEqual(cfg.Replacements, tt.expected) {
+				t.Errorf("expected replacements %q, got %q", tt.expected, cfg.Replacements)
+			}
+		})
+	}
+}
+
+func TestLoadProfileWithoutConfigFile(t *testing.T) {
+	clearEnv()
+	t.Setenv("ASSET_DIR", t.TempDir())
+	t.Setenv("STAGE_PROFILE", "qa")
+
+	if _, err := LoadFile(""); err == nil || !strings.Contains(err.Error(), "no config file") {
+		t.Fatalf("expected error for profile without config file, got %v", err)
+	}
+}
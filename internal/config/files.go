@@ -0,0 +1,97 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// fileSuffix marks STAGE_ variables that name a file holding the value
+const fileSuffix = "_FILE"
+
+// Trimming applied to values read from files
+const (
+	TrimNewline = "newline" // strip trailing line breaks, as left by editors and echo
+	TrimSpace   = "space"   // strip leading and trailing whitespace
+	TrimNone    = "none"    // use the file contents as-is
+)
+
+// loadValueFiles reads the value of each placeholder from its file, taking
+// precedence over the config file. A placeholder set both by STAGE_<NAME> and
+// from a file is an error, as is a file that cannot be read or exceeds
+// FileValueMaxBytes, and a file value named like a request value.
+func (c *Config) loadValueFiles(valueFiles map[string]string) error {
+	// Sorted so the first error reported is deterministic
+	names := make([]string, 0, len(valueFiles))
+	for name := range valueFiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		key := "STAGE_" + name + fileSuffix
+		if _, exists := os.LookupEnv("STAGE_" + name); exists {
+			return fmt.Errorf("both STAGE_%s and %s are set, use only one", name, key)
+		}
+
+		value, err := readValueFile(valueFiles[name], c.FileValueMaxBytes, c.FileValueTrim)
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		c.Replacements[name] = value
+	}
+
+	// Validate ran before the files were read
+	return c.validateRequestValues()
+}
+
+// readValueFile reads a replacement value from path, trimmed according to
+// trim. A maxBytes of 0 means unlimited.
+func readValueFile(path string, maxBytes int, trim string) (string, error) {
+	if path == "" {
+		return "", fmt.Errorf("no file path given")
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("file %s does not exist", path)
+		}
+		return "", fmt.Errorf("cannot open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return "", fmt.Errorf("cannot stat %s: %w", path, err)
+	}
+	if info.IsDir() {
+		return "", fmt.Errorf("%s is a directory, not a file", path)
+	}
+
+	// Read one byte past the limit to detect oversized files, which may not
+	// report their size (e.g. pipes)
+	var r io.Reader = f
+	if maxBytes > 0 {
+		r = io.LimitReader(f, int64(maxBytes)+1)
+	}
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return "", fmt.Errorf("cannot read %s: %w", path, err)
+	}
+	if maxBytes > 0 && len(content) > maxBytes {
+		return "", fmt.Errorf("%s is larger than %d bytes (FILE_VALUE_MAX_BYTES)", path, maxBytes)
+	}
+
+	value := string(content)
+	switch trim {
+	case TrimSpace:
+		value = strings.TrimSpace(value)
+	case TrimNewline, "":
+		value = strings.TrimRight(value, "\r\n")
+	}
+
+	return value, nil
+}
@@ -0,0 +1,108 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadValueFile(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name        string
+		content     string
+		maxBytes    int
+		trim        string
+		expected    string
+		expectError string
+	}{
+		{"trailing newline trimmed", "secret\n", 64, TrimNewline, "secret", ""},
+		{"windows line ending trimmed", "secret\r\n", 64, TrimNewline, "secret", ""},
+		{"default trims newlines", "secret\n\n", 64, "", "secret", ""},
+		{"inner newlines kept", "line1\nline2\n", 64, TrimNewline, "line1\nline2", ""},
+		{"spaces kept with newline trim", "  secret \n", 64, TrimNewline, "  secret ", ""},
+		{"space trim", "  secret \n", 64, TrimSpace, "secret", ""},
+		{"no trim", "secret\n", 64, TrimNone, "secret\n", ""},
+		{"empty file", "", 64, TrimNewline, "", ""},
+		{"at size limit", "12345", 5, TrimNone, "12345", ""},
+		{"over size limit", "123456", 5, TrimNone, "", "larger than 5 bytes"},
+		{"no size limit", "123456", 0, TrimNone, "123456", ""},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, "value-"+string(rune('a'+i)))
+			if err := os.WriteFile(path, []byte(tt.content), 0600); err != nil {
+				t.Fatalf("failed to create file: %v", err)
+			}
+
+			value, err := readValueFile(path, tt.maxBytes, tt.trim)
+			if tt.expectError != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectError) {
+					t.Fatalf("expected error containing %q, got %v", tt.expectError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if value != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, value)
+			}
+		})
+	}
+}
+
+func TestReadValueFileErrors(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name        string
+		path        string
+		expectError string
+	}{
+		{"missing file", filepath.Join(dir, "missing"), "does not exist"},
+		{"directory", dir, "is a directory"},
+		{"empty path", "", "no file path"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := readValueFile(tt.path, 64, TrimNewline)
+			if err == nil || !strings.Contains(err.Error(), tt.expectError) {
+				t.Errorf("expected error containing %q, got %v", tt.expectError, err)
+			}
+		})
+	}
+}
+
+func TestLoadValueFilesErrorNamesVariable(t *testing.T) {
+	cfg := &Config{
+		Replacements:      map[string]string{},
+		FileValueMaxBytes: 64,
+	}
+
+	err := cfg.loadValueFiles(map[string]string{"API_KEY": filepath.Join(t.TempDir(), "missing")})
+	if err == nil || !strings.HasPrefix(err.Error(), "STAGE_API_KEY_FILE: ") {
+		t.Errorf("expected error naming STAGE_API_KEY_FILE, got %v", err)
+	}
+}
+
+func TestLoadValueFilesRequestValueClash(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tenant")
+	if err := os.WriteFile(path, []byte("acme\n"), 0600); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+	cfg := &Config{
+		Replacements:      map[string]string{},
+		FileValueMaxBytes: 64,
+		RequestValues:     []RequestValue{{Name: "TENANT", Source: RequestSubdomain}},
+	}
+
+	err := cfg.loadValueFiles(map[string]string{"TENANT": path})
+	if err == nil || !strings.Contains(err.Error(), "both a replacement and a request value") {
+		t.Errorf("expected request value clash, got %v", err)
+	}
+}
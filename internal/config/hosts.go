@@ -0,0 +1,149 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"sort"
+	"strings"
+)
+
+// VirtualHost serves its own replacements, and optionally its own assets, to
+// requests whose Host header names it
+type VirtualHost struct {
+	Name     string // host name without port, lowercase
+	AssetDir string // defaults to the top-level AssetDir
+	Profile  string // config file profile whose replacements the host uses
+
+	// Replacements are the top-level replacements with those of the host's
+	// profile and the host itself layered over them
+	Replacements map[string]string
+}
+
+// fileHost is a hosts entry of the config file
+type fileHost struct {
+	AssetDir     *string              `yaml:"asset_dir" toml:"asset_dir"`
+	Profile      *string              `yaml:"profile" toml:"profile"`
+	Replacements map[string]fileValue `yaml:"replacements" toml:"replacements"`
+}
+
+// ForHost returns a copy of the configuration with the asset directory,
+// replacements and, if it has one, the profile of host in place of the
+// top-level ones
+func (c *Config) ForHost(host VirtualHost) *Config {
+	hc := *c
+	hc.AssetDir = host.AssetDir
+	hc.Replacements = host.Replacements
+	if host.Profile != "" {
+		hc.Profile = host.Profile
+	}
+	return &hc
+}
+
+// HostNames returns the names of the virtual hosts
+func (c *Config) HostNames() []string {
+	names := make([]string, 0, len(c.Hosts))
+	for _, h := range c.Hosts {
+		names = append(names, h.Name)
+	}
+	return names
+}
+
+// setHosts reads the hosts of the config file at path, sorted by name. Their
+// replacements hold only the profile's and their own until layerHosts runs.
+func (c *Config) setHosts(path string, hosts map[string]fileHost, profiles map[string]fileProfile) error {
+	names := make([]string, 0, len(hosts))
+	for name := range hosts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	c.Hosts = make([]VirtualHost, 0, len(names))
+	for _, name := range names {
+		fh := hosts[name]
+		host := VirtualHost{
+			Name:         strings.ToLower(strings.TrimSpace(name)),
+			Replacements: make(map[string]string),
+		}
+		setString(&host.AssetDir, fh.AssetDir)
+		setString(&host.Profile, fh.Profile)
+
+		if host.Profile != "" {
+			p, ok := profiles[host.Profile]
+			if !ok {
+				return c.errorf(hostKey(host.Name), "host %s uses profile %q, which is not defined, available: %s",
+					host.Name, host.Profile, strings.Join(profileNames(profiles), ", "))
+			}
+			if err := setValues(path, host.Replacements, p.Replacements); err != nil {
+				return err
+			}
+		}
+		if err := setValues(path, host.Replacements, fh.Replacements); err != nil {
+			return err
+		}
+
+		c.Hosts = append(c.Hosts, host)
+	}
+	return nil
+}
+
+// layerHosts puts the top-level replacements, including those from
+// environment variables and value files, under the replacements of each host
+// and gives hosts without an asset directory the top-level one
+func (c *Config) layerHosts() {
+	for i := range c.Hosts {
+		host := &c.Hosts[i]
+		replacements := maps.Clone(c.Replacements)
+		maps.Copy(replacements, host.Replacements)
+		host.Replacements = replacements
+
+		if host.AssetDir == "" {
+			host.AssetDir = c.AssetDir
+		}
+	}
+}
+
+// validateHosts checks host names and asset directories, and that no host
+// replaces a placeholder that is filled per request
+func (c *Config) validateHosts() error {
+	seen := make(map[string]bool, len(c.Hosts))
+	for _, h := range c.Hosts {
+		key := hostKey(h.Name)
+		if h.Name == "" || strings.ContainsAny(h.Name, ":/ \t") {
+			return c.errorf(key, "host name %q must be a bare host name such as qa.example.com, without scheme or port", h.Name)
+		}
+		if seen[h.Name] {
+			return c.errorf(key, "host %s is defined more than once", h.Name)
+		}
+		seen[h.Name] = true
+
+		if h.AssetDir != "" {
+			if _, err := os.Stat(h.AssetDir); os.IsNotExist(err) {
+				return c.errorf(key, "asset directory of host %s does not exist: %s", h.Name, h.AssetDir)
+			}
+		}
+
+		for _, v := range c.RequestValues {
+			if _, ok := h.Replacements[v.Name]; ok {
+				return c.errorf(key, "host %s replaces %s, which is a request value, use only one", h.Name, v.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// hostKey returns the origin key of the hosts entry for name
+func hostKey(name string) string {
+	return "hosts." + name
+}
+
+// setValues copies replacement values from the config file at path to dst
+func setValues(path string, dst map[string]string, values map[string]fileValue) error {
+	for name, value := range values {
+		if name == "" || strings.TrimSpace(name) == "" {
+			return fmt.Errorf("%s: replacement with empty name", path)
+		}
+		dst[name] = string(value)
+	}
+	return nil
+}
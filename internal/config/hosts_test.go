@@ -0,0 +1,134 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadFileHosts(t *testing.T) {
+	clearEnv()
+	defer clearEnv()
+
+	path := writeConfigFile(t, "stage.yaml", `asset_dir: ASSET_DIR
+replacements:
+  APP_NAME: demo
+  API_URL: http://localhost
+profiles:
+  qa:
+    replacements:
+      API_URL: https://api.qa.example.com
+      BANNER: qa
+hosts:
+  qa.example.com:
+    profile: qa
+    replacements:
+      BANNER: QA environment
+  Demo.Example.com:
+    asset_dir: ASSET_DIR/demo
+    replacements:
+      API_URL: https://api.demo.example.com
+`)
+	assetDir := filepath.Dir(path)
+	if err := os.Mkdir(filepath.Join(assetDir, "demo"), 0755); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+	t.Setenv("STAGE_FF_SDK_KEY", "env-key")
+
+	cfg, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("LoadFile failed: %v", err)
+	}
+
+	expectedDefault := map[string]string{"APP_NAME": "demo", "API_URL": "http://localhost", "FF_SDK_KEY": "env-key"}
+	if !reflect.DeepEqual(cfg.Replacements, expectedDefault) {
+		t.Errorf("expected top-level replacements %q, got %q", expectedDefault, cfg.Replacements)
+	}
+
+	expected := []VirtualHost{
+		{
+			Name:     "demo.example.com",
+			AssetDir: filepath.ToSlash(assetDir) + "/demo",
+			Replacements: map[string]string{
+				"APP_NAME": "demo", "API_URL": "https://api.demo.example.com", "FF_SDK_KEY": "env-key",
+			},
+		},
+		{
+			Name:     "qa.example.com",
+			AssetDir: filepath.ToSlash(assetDir),
+			Profile:  "qa",
+			Replacements: map[string]string{
+				"APP_NAME": "demo", "API_URL": "https://api.qa.example.com", "BANNER": "QA environment", "FF_SDK_KEY": "env-key",
+			},
+		},
+	}
+	if !reflect.DeepEqual(cfg.Hosts, expected) {
+		t.Errorf("expected hosts %+v, got %+v", expected, cfg.Hosts)
+	}
+	if names := cfg.HostNames(); !reflect.DeepEqual(names, []string{"demo.example.com", "qa.example.com"}) {
+		t.Errorf("unexpected host names: %v", names)
+	}
+
+	hostCfg := cfg.ForHost(cfg.Hosts[0])
+	if hostCfg.AssetDir != expected[0].AssetDir || hostCfg.Replacements["API_URL"] != "https://api.demo.example.com" {
+		t.Errorf("expected ForHost to use the host's settings, got %s %q", hostCfg.AssetDir, hostCfg.Replacements)
+	}
+	if hostCfg.Profile != "" {
+		t.Errorf("expected a host without a profile to keep the top-level one, got %q", hostCfg.Profile)
+	}
+	if qaCfg := cfg.ForHost(cfg.Hosts[1]); qaCfg.Profile != "qa" {
+		t.Errorf("expected ForHost to use the host's profile, got %q", qaCfg.Profile)
+	}
+	if cfg.AssetDir != filepath.ToSlash(assetDir) || cfg.Replacements["API_URL"] != "http://localhost" {
+		t.Error("expected ForHost to leave the configuration unchanged")
+	}
+}
+
+func TestLoadFileHostErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     string
+		expectError string
+	}{
+		{
+			name:        "unknown profile",
+			content:     "asset_dir: ASSET_DIR\nhosts:\n  qa.example.com:\n    profile: staging\n",
+			expectError: `stage.yaml:3: host qa.example.com uses profile "staging", which is not defined, available: none`,
+		},
+		{
+			name:        "missing asset directory",
+			content:     "asset_dir: ASSET_DIR\nhosts:\n  qa.example.com:\n    asset_dir: ASSET_DIR/missing\n",
+			expectError: "stage.yaml:3: asset directory of host qa.example.com does not exist",
+		},
+		{
+			name:        "port in name",
+			content:     "asset_dir: ASSET_DIR\nhosts:\n  \"qa.example.com:8080\": {}\n",
+			expectError: "must be a bare host name",
+		},
+		{
+			name:        "defined twice",
+			content:     "asset_dir: ASSET_DIR\nhosts:\n  qa.example.com: {}\n  QA.example.com: {}\n",
+			expectError: "host qa.example.com is defined more than once",
+		},
+		{
+			name:        "request value",
+			content:     "asset_dir: ASSET_DIR\nrequest_values:\n  TENANT: subdomain\nhosts:\n  qa.example.com:\n    replacements:\n      TENANT: qa\n",
+			expectError: "host qa.example.com replaces TENANT, which is a request value",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv()
+			defer clearEnv()
+			path := writeConfigFile(t, "stage.yaml", tt.content)
+
+			_, err := LoadFile(path)
+			if err == nil || !strings.Contains(err.Error(), tt.expectError) {
+				t.Fatalf("expected error containing %q, got %v", tt.expectError, err)
+			}
+		})
+	}
+}
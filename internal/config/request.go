@@ -0,0 +1,131 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Sources of request values
+const (
+	RequestHeader    = "header"    // a request header, named by the key
+	RequestCookie    = "cookie"    // a cookie, named by the key
+	RequestHost      = "host"      // the host the request was sent to, without port
+	RequestSubdomain = "subdomain" // the first label of the host, e.g. "acme" in acme.example.com
+)
+
+// RequestValue fills the placeholder Name in each response from the request
+type RequestValue struct {
+	Name   string
+	Source string
+	Key    string // header or cookie name
+}
+
+// String returns the value in the "source[:key]" form used by REQUEST_VALUES
+func (v RequestValue) String() string {
+	if v.Key == "" {
+		return v.Source
+	}
+	return v.Source + ":" + v.Key
+}
+
+// RequestNames returns the names of the request placeholders
+func (c *Config) RequestNames() []string {
+	names := make([]string, 0, len(c.RequestValues))
+	for _, v := range c.RequestValues {
+		names = append(names, v.Name)
+	}
+	return names
+}
+
+// parseRequestValues parses REQUEST_VALUES, a comma-separated list of
+// NAME=source[:key] entries, e.g. "TENANT=subdomain,REGION=header:X-Region"
+func parseRequestValues(value string) ([]RequestValue, error) {
+	var values []RequestValue
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, source, ok := strings.Cut(item, "=")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("invalid REQUEST_VALUES entry %q, expected NAME=source[:key]", item)
+		}
+		values = append(values, newRequestValue(name, source))
+	}
+	return values, nil
+}
+
+// fileRequestValues converts the request_values map of a config file, sorted
+// by name so errors are deterministic
+func fileRequestValues(values map[string]string) []RequestValue {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	result := make([]RequestValue, 0, len(names))
+	for _, name := range names {
+		result = append(result, newRequestValue(name, values[name]))
+	}
+	return result
+}
+
+// newRequestValue builds a RequestValue from a name and "source[:key]"
+func newRequestValue(name, spec string) RequestValue {
+	source, key, _ := strings.Cut(spec, ":")
+	return RequestValue{
+		Name:   strings.TrimSpace(name),
+		Source: strings.ToLower(strings.TrimSpace(source)),
+		Key:    strings.TrimSpace(key),
+	}
+}
+
+// validateRequestValues checks the sources of request values, and that no
+// placeholder is filled both per request and at startup
+func (c *Config) validateRequestValues() error {
+	seen := make(map[string]bool, len(c.RequestValues))
+	for _, v := range c.RequestValues {
+		if !validName(v.Name) {
+			return c.errorf("REQUEST_VALUES", "request value name %q must only contain letters, digits and underscores", v.Name)
+		}
+		if seen[v.Name] {
+			return c.errorf("REQUEST_VALUES", "request value %s is defined more than once", v.Name)
+		}
+		seen[v.Name] = true
+
+		switch v.Source {
+		case RequestHeader, RequestCookie:
+			if v.Key == "" {
+				return c.errorf("REQUEST_VALUES", "request value %s needs a %s name, e.g. %s:X-Region", v.Name, v.Source, v.Source)
+			}
+		case RequestHost, RequestSubdomain:
+			if v.Key != "" {
+				return c.errorf("REQUEST_VALUES", "request value %s: %s takes no name, got: %s", v.Name, v.Source, v)
+			}
+		default:
+			return c.errorf("REQUEST_VALUES", "request value %s must come from %q, %q, %q or %q, got: %q",
+				v.Name, RequestHeader, RequestCookie, RequestHost, RequestSubdomain, v.Source)
+		}
+
+		if _, ok := c.Replacements[v.Name]; ok {
+			return c.errorf("REQUEST_VALUES", "%s is both a replacement and a request value, use only one", v.Name)
+		}
+	}
+	return nil
+}
+
+// validName reports whether name can be written as a placeholder
+func validName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		b := name[i]
+		if b != '_' && (b < 'A' || b > 'Z') && (b < 'a' || b > 'z') && (b < '0' || b > '9') {
+			return false
+		}
+	}
+	return true
+}
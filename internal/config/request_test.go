@@ -0,0 +1,156 @@
+package config
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseRequestValues(t *testing.T) {
+	tests := []struct {
+		name        string
+		value       string
+		expected    []RequestValue
+		expectError bool
+	}{
+		{name: "empty", value: "", expected: nil},
+		{
+			name:  "every source",
+			value: "TENANT=subdomain, REGION=header:X-Region,THEME=Cookie:theme,SITE=host,",
+			expected: []RequestValue{
+				{Name: "TENANT", Source: RequestSubdomain},
+				{Name: "REGION", Source: RequestHeader, Key: "X-Region"},
+				{Name: "THEME", Source: RequestCookie, Key: "theme"},
+				{Name: "SITE", Source: RequestHost},
+			},
+		},
+		{name: "missing separator", value: "TENANT", expectError: true},
+		{name: "missing name", value: "=host", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, err := parseRequestValues(tt.value)
+
+			if tt.expectError {
+				if err == nil {
+					t.Error("expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(values, tt.expected) {
+				t.Errorf("expected %+v, got %+v", tt.expected, values)
+			}
+		})
+	}
+}
+
+func TestValidateRequestValues(t *testing.T) {
+	tests := []struct {
+		name        string
+		values      []RequestValue
+		expectError string
+	}{
+		{
+			name: "valid",
+			values: []RequestValue{
+				{Name: "TENANT", Source: RequestSubdomain},
+				{Name: "REGION", Source: RequestHeader, Key: "X-Region"},
+			},
+		},
+		{
+			name:        "unknown source",
+			values:      []RequestValue{{Name: "TENANT", Source: "query", Key: "tenant"}},
+			expectError: "must come from",
+		},
+		{
+			name:        "cookie without name",
+			values:      []RequestValue{{Name: "THEME", Source: RequestCookie}},
+			expectError: "needs a cookie name",
+		},
+		{
+			name:        "host with name",
+			values:      []RequestValue{{Name: "SITE", Source: RequestHost, Key: "X"}},
+			expectError: "host takes no name",
+		},
+		{
+			name:        "invalid name",
+			values:      []RequestValue{{Name: "MY-TENANT", Source: RequestSubdomain}},
+			expectError: "must only contain letters",
+		},
+		{
+			name: "duplicate",
+			values: []RequestValue{
+				{Name: "TENANT", Source: RequestSubdomain},
+				{Name: "TENANT", Source: RequestHost},
+			},
+			expectError: "defined more than once",
+		},
+		{
+			name:        "also a replacement",
+			values:      []RequestValue{{Name: "API_URL", Source: RequestHost}},
+			expectError: "both a replacement and a request value",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Replacements:  map[string]string{"API_URL": "https://api.example.com"},
+				RequestValues: tt.values,
+			}
+
+			err := cfg.validateRequestValues()
+			if tt.expectError == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.expectError) {
+				t.Errorf("expected error containing %q, got %v", tt.expectError, err)
+			}
+		})
+	}
+}
+
+func TestLoadRequestValues(t *testing.T) {
+	clearEnv()
+	defer clearEnv()
+
+	path := writeConfigFile(t, "stage.yaml", `asset_dir: ASSET_DIR
+request_values:
+  TENANT: subdomain
+  REGION: "header:X-Region"
+`)
+
+	cfg, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []RequestValue{
+		{Name: "REGION", Source: RequestHeader, Key: "X-Region"},
+		{Name: "TENANT", Source: RequestSubdomain},
+	}
+	if !reflect.DeepEqual(cfg.RequestValues, expected) {
+		t.Errorf("expected %+v from the file, got %+v", expected, cfg.RequestValues)
+	}
+	if names := cfg.RequestNames(); !reflect.DeepEqual(names, []string{"REGION", "TENANT"}) {
+		t.Errorf("expected names REGION and TENANT, got %v", names)
+	}
+
+	// The environment variable replaces the file's values entirely
+	os.Setenv("REQUEST_VALUES", "THEME=cookie:theme")
+	cfg, err = LoadFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected = []RequestValue{{Name: "THEME", Source: RequestCookie, Key: "theme"}}
+	if !reflect.DeepEqual(cfg.RequestValues, expected) {
+		t.Errorf("expected %+v from REQUEST_VALUES, got %+v", expected, cfg.RequestValues)
+	}
+}
@@ -0,0 +1,151 @@
+package server
+
+import (
+	"net/http"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cb-demos/stage/internal/config"
+	"github.com/cb-demos/stage/internal/transformer"
+	"github.com/gin-gonic/gin"
+)
+
+// Default Cache-Control values used when no configured rule matches
+const (
+	// Fingerprinted files change name whenever their content changes, unless
+	// stage rewrote the content, which changes with the configuration
+	cacheImmutable = "public, max-age=31536000, immutable"
+	// Everything else must be revalidated so an env change is picked up;
+	// ETag and Last-Modified keep revalidation cheap
+	cacheRevalidate = "no-cache"
+)
+
+// hexHashPattern matches content hashes as emitted by webpack, Angular and
+// esbuild, e.g. main.3f9a2b1c.js or app-5D2A9F1B.css
+var hexHashPattern = regexp.MustCompile(`^[0-9a-fA-F]{8,64}$`)
+
+// shortHashPattern matches the 8 character base64url hashes emitted by
+// Vite/Rollup, e.g. index-BdH3kx9Q.js
+var shortHashPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{8}$`)
+
+// cacheControlFor returns the Cache-Control value for a path relative to the
+// asset directory, served as transformed by stage or as it is on disk.
+// Configured rules are checked in order; the first match wins.
+func cacheControlFor(rules []config.CacheRule, relPath string, transformed bool) string {
+	for _, rule := range rules {
+		if transformer.MatchGlob(rule.Pattern, relPath) {
+			return rule.CacheControl
+		}
+	}
+
+	if !transformed && isFingerprinted(relPath) {
+		return cacheImmutable
+	}
+
+	return cacheRevalidate
+}
+
+// isFingerprinted detects file names carrying a content hash, which can be
+// cached forever because a new build produces a new name
+func isFingerprinted(relPath string) bool {
+	name := path.Base(relPath)
+
+	// The first dot-separated part is the base name, the last the extension
+	parts := strings.Split(name, ".")
+	if len(parts) < 2 {
+		return false
+	}
+
+	for i, part := range parts[:len(parts)-1] {
+		// main.3f9a2b1c.js
+		if i > 0 && isHexHash(part) {
+			return true
+		}
+
+		// index-BdH3kx9Q.js, app-5D2A9F1B.css
+		for j := 0; j < len(part); j++ {
+			if part[j] != '-' {
+				continue
+			}
+			suffix := part[j+1:]
+			if isHexHash(suffix) || isShortHash(suffix) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// isHexHash reports whether s is a hex content hash. Hashes mix digits and
+// letters, which tells them from dates such as 20240101.
+func isHexHash(s string) bool {
+	return hexHashPattern.MatchString(s) && strings.ContainsAny(s, "0123456789") &&
+		strings.ContainsAny(s, "abcdefABCDEF")
+}
+
+// isShortHash reports whether s is a base64url content hash. Hashes mix
+// digits with upper- and lower-case letters and switch between them often,
+// which tells them from words such as "summer24" or "Summer24". When in
+// doubt a file is not fingerprinted: revalidating is only slower.
+func isShortHash(s string) bool {
+	if !shortHashPattern.MatchString(s) {
+		return false
+	}
+
+	var digit, upper, lower bool
+	runs := 0
+	prev := -1
+	for i := 0; i < len(s); i++ {
+		class := 0
+		switch c := s[i]; {
+		case c >= '0' && c <= '9':
+			digit, class = true, 1
+		case c >= 'A' && c <= 'Z':
+			upper, class = true, 2
+		case c >= 'a' && c <= 'z':
+			lower, class = true, 3
+		}
+		if class != prev {
+			runs++
+			prev = class
+		}
+	}
+	return digit && upper && lower && runs >= 4
+}
+
+// setCacheHeaders applies the caching policy for relPath to the response,
+// whose content is transformed or as it is on disk. An Expires header is
+// derived from the directives for HTTP/1.0 caches.
+func (s *site) setCacheHeaders(c *gin.Context, relPath string, transformed bool) {
+	cacheControl := cacheControlFor(s.config.CacheRules, relPath, transformed)
+	c.Header("Cache-Control", cacheControl)
+
+	if expires, ok := expiresFor(cacheControl, time.Now()); ok {
+		c.Header("Expires", expires)
+	}
+}
+
+// expiresFor computes the Expires header matching a Cache-Control value
+func expiresFor(cacheControl string, now time.Time) (string, bool) {
+	for _, directive := range strings.Split(cacheControl, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+
+		switch strings.ToLower(name) {
+		case "no-cache", "no-store":
+			// An invalid date such as "0" means already expired
+			return "0", true
+		case "max-age":
+			seconds, err := strconv.Atoi(strings.Trim(value, `"`))
+			if err != nil {
+				return "", false
+			}
+			return now.Add(time.Duration(seconds) * time.Second).UTC().Format(http.TimeFormat), true
+		}
+	}
+
+	return "", false
+}
@@ -0,0 +1,184 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cb-demos/stage/internal/config"
+	"github.com/cb-demos/stage/internal/transformer"
+)
+
+func TestIsFingerprinted(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected bool
+	}{
+		// webpack / CRA / Angular
+		{"static/js/main.3f9a2b1c.js", true},
+		{"static/js/main.3f9a2b1c.chunk.js", true},
+		{"main.3f9a2b1c5d6e7f80.js.map", true},
+		{"polyfills-5D2A9F1B.js", true},
+		// Vite / Rollup
+		{"assets/index-BdH3kx9Q.js", true},
+		{"assets/index-B_x9-Qa2.css", true},
+		// Not fingerprinted
+		{"index.html", false},
+		{"app.js", false},
+		{"react-dom.production.min.js", false},
+		{"my-component.js", false},
+		{"bootstrap-template.css", false},
+		{"app-v2-12.js", false},
+		{"noextension", false},
+		{"3f9a2b1c.js", false},
+		{"photo-20240101.jpg", false},
+		{"report.20241016.pdf", false},
+		{"banner-summer24.jpg", false},
+		{"banner-Summer24.jpg", false},
+		{"logo-deadbeef.svg", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if result := isFingerprinted(tt.path); result != tt.expected {
+				t.Errorf("isFingerprinted(%q) = %v, expected %v", tt.path, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCacheControlFor(t *testing.T) {
+	rules := []config.CacheRule{
+		{Pattern: "*.html", CacheControl: "no-store"},
+		{Pattern: "static/**", CacheControl: "public, max-age=3600"},
+		{Pattern: "static/special.js", CacheControl: "never reached"},
+	}
+
+	tests := []struct {
+		path        string
+		transformed bool
+		expected    string
+	}{
+		{"index.html", false, "no-store"},
+		{"static/special.js", true, "public, max-age=3600"},
+		{"assets/index-BdH3kx9Q.js", false, cacheImmutable},
+		{"assets/index-CdH3kx9Q.js", true, cacheRevalidate},
+		{"app.js", false, cacheRevalidate},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if result := cacheControlFor(rules, tt.path, tt.transformed); result != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestExpiresFor(t *testing.T) {
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		cacheControl string
+		expected     string
+		ok           bool
+	}{
+		{"no-cache", "0", true},
+		{"private, no-store", "0", true},
+		{"public, max-age=3600", "Thu, 02 Jan 2025 04:04:05 GMT", true},
+		{"public, max-age=31536000, immutable", "Fri, 02 Jan 2026 03:04:05 GMT", true},
+		{"public", "", false},
+		{"max-age=soon", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.cacheControl, func(t *testing.T) {
+			result, ok := expiresFor(tt.cacheControl, now)
+			if ok != tt.ok || result != tt.expected {
+				t.Errorf("expected (%q, %v), got (%q, %v)", tt.expected, tt.ok, result, ok)
+			}
+		})
+	}
+}
+
+func TestCacheHeaders(t *testing.T) {
+	tempDir := t.TempDir()
+
+	if err := os.MkdirAll(filepath.Join(tempDir, "assets"), 0755); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tempDir, "assets", "logo-a1b2c3d4.png"), []byte("png"), 0644); err != nil {
+		t.Fatalf("failed to create test file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tempDir, "robots.txt"), []byte("robots"), 0644); err != nil {
+		t.Fatalf("failed to create test file: %v", err)
+	}
+
+	cfg := &config.Config{
+		Port:         "8080",
+		AssetDir:     tempDir,
+		Host:         "0.0.0.0",
+		Replacements: map[string]string{},
+		CacheRules: []config.CacheRule{
+			{Pattern: "robots.txt", CacheControl: "public, max-age=60"},
+		},
+	}
+
+	cache := transformer.NewCache()
+	cache.Set("index.html", []byte("<html>index</html>"))
+	cache.Set("assets/index-BdH3kx9Q.js", []byte("console.log('hashed');"))
+	cache.SetEntry("assets/main-CdH3kx9Q.js", &transformer.Entry{
+		Content:     []byte("fetch('https://api.example.com');"),
+		Transformed: true,
+	})
+
+	srv := New(cfg, cache, testLogger())
+
+	tests := []struct {
+		name     string
+		path     string
+		expected string
+	}{
+		{"cached html", "/index.html", cacheRevalidate},
+		{"spa fallback", "/dashboard", cacheRevalidate},
+		{"cached fingerprinted", "/assets/index-BdH3kx9Q.js", cacheImmutable},
+		{"transformed fingerprinted", "/assets/main-CdH3kx9Q.js", cacheRevalidate},
+		{"disk fingerprinted", "/assets/logo-a1b2c3d4.png", cacheImmutable},
+		{"disk with rule", "/robots.txt", "public, max-age=60"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			w := httptest.NewRecorder()
+			srv.router.ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d", w.Code)
+			}
+			if cc := w.Header().Get("Cache-Control"); cc != tt.expected {
+				t.Errorf("expected Cache-Control %q, got %q", tt.expected, cc)
+			}
+			if w.Header().Get("Expires") == "" {
+				t.Error("expected Expires header")
+			}
+		})
+	}
+
+	// Revalidation responses carry the policy too
+	req := httptest.NewRequest(http.MethodGet, "/index.html", nil)
+	w := httptest.NewRecorder()
+	srv.router.ServeHTTP(w, req)
+
+	req = httptest.NewRequest(http.MethodGet, "/index.html", nil)
+	req.Header.Set("If-None-Match", w.Header().Get("ETag"))
+	w = httptest.NewRecorder()
+	srv.router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotModified || w.Header().Get("Cache-Control") != cacheRevalidate {
+		t.Errorf("expected 304 with Cache-Control, got %d %q", w.Code, w.Header().Get("Cache-Control"))
+	}
+}
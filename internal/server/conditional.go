@@ -0,0 +1,61 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// variantETag derives the entity tag of a content-coded variant. Each
+// encoding is a different representation and needs its own strong ETag.
+func variantETag(etag, encoding string) string {
+	if encoding == "" {
+		return etag
+	}
+	return strings.TrimSuffix(etag, `"`) + "-" + encoding + `"`
+}
+
+// etagMatches reports whether an If-None-Match header matches etag, using
+// the weak comparison RFC 9110 requires for If-None-Match
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+
+	if strings.TrimSpace(ifNoneMatch) == "*" {
+		return true
+	}
+
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(candidate), "W/") == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// notModified evaluates the conditional request headers against a
+// representation's validators. If-None-Match takes precedence; when it is
+// present, If-Modified-Since is ignored.
+func notModified(r *http.Request, etag string, modTime time.Time) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		return etagMatches(ifNoneMatch, etag)
+	}
+
+	if modTime.IsZero() {
+		return false
+	}
+
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+
+	return !modTime.Truncate(time.Second).After(since)
+}
@@ -0,0 +1,183 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cb-demos/stage/internal/config"
+	"github.com/cb-demos/stage/internal/transformer"
+)
+
+func TestEtagMatches(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   string
+		etag     string
+		expected bool
+	}{
+		{"empty header", "", `"abc"`, false},
+		{"exact match", `"abc"`, `"abc"`, true},
+		{"no match", `"def"`, `"abc"`, false},
+		{"list match", `"def", "abc"`, `"abc"`, true},
+		{"weak header matches strong etag", `W/"abc"`, `"abc"`, true},
+		{"wildcard", "*", `"abc"`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := etagMatches(tt.header, tt.etag); result != tt.expected {
+				t.Errorf("etagMatches(%q, %q) = %v, expected %v", tt.header, tt.etag, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestVariantETag(t *testing.T) {
+	if etag := variantETag(`"abc"`, ""); etag != `"abc"` {
+		t.Errorf("expected identity ETag unchanged, got %s", etag)
+	}
+	if etag := variantETag(`"abc"`, "br"); etag != `"abc-br"` {
+		t.Errorf("expected brotli ETag, got %s", etag)
+	}
+}
+
+func TestNotModified(t *testing.T) {
+	modTime := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		method   string
+		headers  map[string]string
+		expected bool
+	}{
+		{"no conditionals", http.MethodGet, nil, false},
+		{"etag match", http.MethodGet, map[string]string{"If-None-Match": `"abc"`}, true},
+		{"etag mismatch", http.MethodGet, map[string]string{"If-None-Match": `"old"`}, false},
+		{"modified since earlier", http.MethodGet, map[string]string{"If-Modified-Since": modTime.Add(-time.Hour).Format(http.TimeFormat)}, false},
+		{"not modified since", http.MethodGet, map[string]string{"If-Modified-Since": modTime.Format(http.TimeFormat)}, true},
+		{"etag takes precedence", http.MethodGet, map[string]string{
+			"If-None-Match":     `"old"`,
+			"If-Modified-Since": modTime.Format(http.TimeFormat),
+		}, false},
+		{"invalid date", http.MethodGet, map[string]string{"If-Modified-Since": "yesterday"}, false},
+		{"head request", http.MethodHead, map[string]string{"If-None-Match": `"abc"`}, true},
+		{"post ignored", http.MethodPost, map[string]string{"If-None-Match": `"abc"`}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/app.js", nil)
+			for key, value := range tt.headers {
+				req.Header.Set(key, value)
+			}
+
+			if result := notModified(req, `"abc"`, modTime); result != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestConditionalRequests(t *testing.T) {
+	tempDir := t.TempDir()
+
+	cfg := &config.Config{
+		Port:         "8080",
+		AssetDir:     tempDir,
+		Host:         "0.0.0.0",
+		Replacements: map[string]string{},
+	}
+
+	cache := transformer.NewCache()
+	cache.Set("app.js", []byte("console.log('app');"))
+	cache.Set("index.html", []byte("<html>index</html>"))
+
+	srv := New(cfg, cache, testLogger())
+
+	for _, path := range []string{"/app.js", "/dashboard"} {
+		t.Run(path, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			w := httptest.NewRecorder()
+			srv.router.ServeHTTP(w, req)
+
+			etag := w.Header().Get("ETag")
+			lastModified := w.Header().Get("Last-Modified")
+			if w.Code != http.StatusOK || etag == "" || lastModified == "" {
+				t.Fatalf("expected 200 with validators, got %d etag=%q last-modified=%q", w.Code, etag, lastModified)
+			}
+
+			req = httptest.NewRequest(http.MethodGet, path, nil)
+			req.Header.Set("If-None-Match", etag)
+			w = httptest.NewRecorder()
+			srv.router.ServeHTTP(w, req)
+
+			if w.Code != http.StatusNotModified {
+				t.Errorf("expected 304 for matching ETag, got %d", w.Code)
+			}
+			if w.Body.Len() != 0 {
+				t.Errorf("expected empty body, got %q", w.Body.String())
+			}
+
+			req = httptest.NewRequest(http.MethodGet, path, nil)
+			req.Header.Set("If-Modified-Since", lastModified)
+			w = httptest.NewRecorder()
+			srv.router.ServeHTTP(w, req)
+
+			if w.Code != http.StatusNotModified {
+				t.Errorf("expected 304 for If-Modified-Since, got %d", w.Code)
+			}
+
+			req = httptest.NewRequest(http.MethodGet, path, nil)
+			req.Header.Set("If-None-Match", `"stale"`)
+			w = httptest.NewRecorder()
+			srv.router.ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Errorf("expected 200 for stale ETag, got %d", w.Code)
+			}
+		})
+	}
+}
+
+func TestConditionalRequestPerEncoding(t *testing.T) {
+	tempDir := t.TempDir()
+
+	cfg := &config.Config{
+		Port:         "8080",
+		AssetDir:     tempDir,
+		Host:         "0.0.0.0",
+		Replacements: map[string]string{},
+	}
+
+	cache := transformer.NewCache()
+	cache.SetEntry("app.js", &transformer.Entry{Content: []byte("content"), Gzip: []byte("gzip")})
+
+	srv := New(cfg, cache, testLogger())
+
+	req := httptest.NewRequest(http.MethodGet, "/app.js", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	srv.router.ServeHTTP(w, req)
+	gzipETag := w.Header().Get("ETag")
+
+	req = httptest.NewRequest(http.MethodGet, "/app.js", nil)
+	w = httptest.NewRecorder()
+	srv.router.ServeHTTP(w, req)
+	identityETag := w.Header().Get("ETag")
+
+	if gzipETag == identityETag {
+		t.Fatalf("expected distinct ETags per encoding, got %s for both", gzipETag)
+	}
+
+	// A gzip validator must not revalidate the identity representation
+	req = httptest.NewRequest(http.MethodGet, "/app.js", nil)
+	req.Header.Set("If-None-Match", gzipETag)
+	w = httptest.NewRecorder()
+	srv.router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected 200, got %d", w.Code)
+	}
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/cb-demos/stage/internal/transformer"
+)
+
+// negotiateEncoding picks the best precompressed variant of entry the client
+// accepts, returning "br", "gzip" or "" for the uncompressed content.
+// Brotli is preferred over gzip when both are equally acceptable.
+func negotiateEncoding(acceptEncoding string, entry *transformer.Entry) string {
+	accepted := parseAcceptEncoding(acceptEncoding)
+
+	best, bestQ := "", 0.0
+	for _, candidate := range []struct {
+		name    string
+		content []byte
+	}{
+		{"br", entry.Brotli},
+		{"gzip", entry.Gzip},
+	} {
+		if candidate.content == nil {
+			continue
+		}
+
+		q, ok := accepted[candidate.name]
+		if !ok {
+			q, ok = accepted["*"]
+		}
+		if ok && q > bestQ {
+			best, bestQ = candidate.name, q
+		}
+	}
+
+	return best
+}
+
+// parseAcceptEncoding parses an Accept-Encoding header into a map of coding
+// to quality value. Codings with q=0 are kept so an explicit refusal is not
+// overridden by a wildcard.
+func parseAcceptEncoding(header string) map[string]float64 {
+	accepted := make(map[string]float64)
+
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding == "" {
+			continue
+		}
+
+		q := 1.0
+		if name, value, found := strings.Cut(strings.TrimSpace(params), "="); found && strings.TrimSpace(name) == "q" {
+			parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+
+		accepted[coding] = q
+	}
+
+	return accepted
+}
@@ -0,0 +1,135 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cb-demos/stage/internal/config"
+	"github.com/cb-demos/stage/internal/transformer"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	both := &transformer.Entry{Content: []byte("c"), Gzip: []byte("g"), Brotli: []byte("b")}
+	gzipOnly := &transformer.Entry{Content: []byte("c"), Gzip: []byte("g")}
+
+	tests := []struct {
+		name     string
+		header   string
+		entry    *transformer.Entry
+		expected string
+	}{
+		{"no header", "", both, ""},
+		{"identity only", "identity", both, ""},
+		{"gzip", "gzip", both, "gzip"},
+		{"brotli preferred on tie", "gzip, deflate, br", both, "br"},
+		{"quality wins", "br;q=0.5, gzip;q=0.8", both, "gzip"},
+		{"brotli refused", "gzip, br;q=0", both, "gzip"},
+		{"wildcard", "*", both, "br"},
+		{"wildcard does not override refusal", "br;q=0, *", both, "gzip"},
+		{"variant missing", "br", gzipOnly, ""},
+		{"case insensitive", "GZIP", gzipOnly, "gzip"},
+		{"malformed quality ignored", "br;q=high, gzip", both, "gzip"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := negotiateEncoding(tt.header, tt.entry); result != tt.expected {
+				t.Errorf("for %q expected %q, got %q", tt.header, tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestServeCompressedVariants(t *testing.T) {
+	tempDir := t.TempDir()
+
+	cfg := &config.Config{
+		Port:         "8080",
+		AssetDir:     tempDir,
+		Host:         "0.0.0.0",
+		Replacements: map[string]string{},
+	}
+
+	content := bytes.Repeat([]byte("console.log('compress me');\n"), 100)
+	var gz bytes.Buffer
+	gw := gzip.NewWriter(&gz)
+	gw.Write(content)
+	gw.Close()
+
+	cache := transformer.NewCache()
+	cache.SetEntry("app.js", &transformer.Entry{Content: content, Gzip: gz.Bytes(), Brotli: []byte("brotli-bytes")})
+	cache.Set("small.js", []byte("tiny"))
+
+	srv := New(cfg, cache, testLogger())
+
+	t.Run("gzip", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/app.js", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		w := httptest.NewRecorder()
+		srv.router.ServeHTTP(w, req)
+
+		if w.Header().Get("Content-Encoding") != "gzip" {
+			t.Fatalf("expected gzip encoding, got %q", w.Header().Get("Content-Encoding"))
+		}
+		if w.Header().Get("Vary") != "Accept-Encoding" {
+			t.Errorf("expected Vary: Accept-Encoding, got %q", w.Header().Get("Vary"))
+		}
+
+		reader, err := gzip.NewReader(w.Body)
+		if err != nil {
+			t.Fatalf("failed to read gzip body: %v", err)
+		}
+		decoded, _ := io.ReadAll(reader)
+		if !bytes.Equal(decoded, content) {
+			t.Error("decoded body does not match content")
+		}
+	})
+
+	t.Run("brotli", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/app.js", nil)
+		req.Header.Set("Accept-Encoding", "gzip, br")
+		w := httptest.NewRecorder()
+		srv.router.ServeHTTP(w, req)
+
+		if w.Header().Get("Content-Encoding") != "br" {
+			t.Fatalf("expected br encoding, got %q", w.Header().Get("Content-Encoding"))
+		}
+		if w.Body.String() != "brotli-bytes" {
+			t.Errorf("expected brotli variant, got %q", w.Body.String())
+		}
+	})
+
+	t.Run("identity", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/app.js", nil)
+		w := httptest.NewRecorder()
+		srv.router.ServeHTTP(w, req)
+
+		if w.Header().Get("Content-Encoding") != "" {
+			t.Errorf("expected no encoding, got %q", w.Header().Get("Content-Encoding"))
+		}
+		if w.Header().Get("Vary") != "Accept-Encoding" {
+			t.Errorf("expected Vary: Accept-Encoding, got %q", w.Header().Get("Vary"))
+		}
+		if !bytes.Equal(w.Body.Bytes(), content) {
+			t.Error("expected uncompressed content")
+		}
+	})
+
+	t.Run("no variants", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/small.js", nil)
+		req.Header.Set("Accept-Encoding", "gzip, br")
+		w := httptest.NewRecorder()
+		srv.router.ServeHTTP(w, req)
+
+		if w.Header().Get("Content-Encoding") != "" {
+			t.Errorf("expected no encoding, got %q", w.Header().Get("Content-Encoding"))
+		}
+		if w.Body.String() != "tiny" {
+			t.Errorf("expected raw content, got %q", w.Body.String())
+		}
+	})
+}
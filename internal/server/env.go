@@ -0,0 +1,88 @@
+package server
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"path"
+
+	"github.com/cb-demos/stage/internal/transformer"
+	"github.com/gin-gonic/gin"
+)
+
+// runtimeEnv holds the pre-rendered /__stage/env.* responses. Replacements
+// only change on reload or restart, so the bodies are rendered once per site.
+type runtimeEnv struct {
+	js       []byte
+	json     []byte
+	jsETag   string
+	jsonETag string
+}
+
+// newRuntimeEnv renders the allowlisted replacements as a JSON object and as
+// a script assigning it to window.__ENV__
+func newRuntimeEnv(replacements map[string]string, allowlist []string) *runtimeEnv {
+	values := filterAllowlisted(replacements, allowlist)
+
+	// encoding/json escapes <, > and & so the object is safe inside <script>
+	body, err := json.Marshal(values)
+	if err != nil {
+		// A map[string]string always marshals; keep the endpoint usable regardless
+		slog.Error("Failed to render runtime env", "error", err)
+		body = []byte("{}")
+	}
+
+	js := append([]byte("window.__ENV__ = Object.freeze("), body...)
+	js = append(js, []byte(");\n")...)
+
+	return &runtimeEnv{
+		js:       js,
+		json:     body,
+		jsETag:   transformer.ETag(js),
+		jsonETag: transformer.ETag(body),
+	}
+}
+
+// filterAllowlisted returns the replacements whose names match any of the
+// allowlist glob patterns. An empty allowlist exposes nothing.
+func filterAllowlisted(replacements map[string]string, allowlist []string) map[string]string {
+	values := make(map[string]string)
+	for name, value := range replacements {
+		for _, pattern := range allowlist {
+			if matched, _ := path.Match(pattern, name); matched {
+				values[name] = value
+				break
+			}
+		}
+	}
+	return values
+}
+
+// handleEnvJS serves the runtime env of the requested host as a script for
+// <script src>
+func (s *Server) handleEnvJS(c *gin.Context) {
+	env := s.siteFor(c.Request).runtimeEnv
+	serveRuntimeEnv(c, "application/javascript; charset=utf-8", env.js, env.jsETag)
+}
+
+// handleEnvJSON serves the runtime env of the requested host as JSON for
+// fetch()
+func (s *Server) handleEnvJSON(c *gin.Context) {
+	env := s.siteFor(c.Request).runtimeEnv
+	serveRuntimeEnv(c, "application/json; charset=utf-8", env.json, env.jsonETag)
+}
+
+// serveRuntimeEnv writes a runtime env body. Browsers must revalidate on every
+// load so a reload or restart with new values is picked up immediately, but
+// unchanged values are answered with 304 Not Modified.
+func serveRuntimeEnv(c *gin.Context, contentType string, body []byte, etag string) {
+	c.Header("Cache-Control", "no-cache")
+	c.Header("ETag", etag)
+
+	if etagMatches(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	c.Data(http.StatusOK, contentType, body)
+}
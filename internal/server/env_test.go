@@ -0,0 +1,140 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cb-demos/stage/internal/config"
+	"github.com/cb-demos/stage/internal/transformer"
+)
+
+func TestFilterAllowlisted(t *testing.T) {
+	replacements := map[string]string{
+		"API_ENDPOINT":   "https://api.test.com",
+		"PUBLIC_TITLE":   "Test",
+		"PUBLIC_THEME":   "dark",
+		"FM_KEY":         "secret",
+		"PRIVATE_SECRET": "secret",
+	}
+
+	tests := []struct {
+		name      string
+		allowlist []string
+		expected  []string
+	}{
+		{"empty allowlist exposes nothing", nil, nil},
+		{"exact name", []string{"API_ENDPOINT"}, []string{"API_ENDPOINT"}},
+		{"glob pattern", []string{"PUBLIC_*"}, []string{"PUBLIC_THEME", "PUBLIC_TITLE"}},
+		{"mixed", []string{"API_ENDPOINT", "PUBLIC_T*"}, []string{"API_ENDPOINT", "PUBLIC_THEME", "PUBLIC_TITLE"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := filterAllowlisted(replacements, tt.allowlist)
+
+			if len(result) != len(tt.expected) {
+				t.Fatalf("expected %v, got %v", tt.expected, result)
+			}
+			for _, name := range tt.expected {
+				if result[name] != replacements[name] {
+					t.Errorf("expected %s to be exposed", name)
+				}
+			}
+		})
+	}
+}
+
+func TestRuntimeEnvEndpoints(t *testing.T) {
+	tempDir := t.TempDir()
+
+	cfg := &config.Config{
+		Port:     "8080",
+		AssetDir: tempDir,
+		Host:     "0.0.0.0",
+		Replacements: map[string]string{
+			"API_ENDPOINT": "https://api.test.com",
+			"FM_KEY":       "secret",
+			"TITLE":        "</script><script>alert(1)</script>",
+		},
+		RuntimeEnvAllowlist: []string{"API_ENDPOINT", "TITLE"},
+	}
+
+	srv := New(cfg, transformer.NewCache(), testLogger())
+
+	t.Run("json", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/__stage/env.json", nil)
+		w := httptest.NewRecorder()
+		srv.router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", w.Code)
+		}
+
+		if ct := w.Header().Get("Content-Type"); ct != "application/json; charset=utf-8" {
+			t.Errorf("unexpected content type %s", ct)
+		}
+
+		var values map[string]string
+		if err := json.Unmarshal(w.Body.Bytes(), &values); err != nil {
+			t.Fatalf("failed to parse response: %v", err)
+		}
+
+		if values["API_ENDPOINT"] != "https://api.test.com" {
+			t.Errorf("expected API_ENDPOINT to be exposed, got %v", values)
+		}
+
+		if _, exists := values["FM_KEY"]; exists {
+			t.Error("expected FM_KEY not to be exposed")
+		}
+	})
+
+	t.Run("js", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/__stage/env.js", nil)
+		w := httptest.NewRecorder()
+		srv.router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", w.Code)
+		}
+
+		body := w.Body.String()
+		if !strings.HasPrefix(body, "window.__ENV__ = ") {
+			t.Errorf("expected window.__ENV__ assignment, got %s", body)
+		}
+
+		if strings.Contains(body, "</script>") {
+			t.Errorf("expected script tags to be escaped, got %s", body)
+		}
+
+		if cc := w.Header().Get("Cache-Control"); cc != "no-cache" {
+			t.Errorf("expected Cache-Control no-cache, got %s", cc)
+		}
+	})
+
+	t.Run("not modified", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/__stage/env.js", nil)
+		w := httptest.NewRecorder()
+		srv.router.ServeHTTP(w, req)
+
+		etag := w.Header().Get("ETag")
+		if etag == "" {
+			t.Fatal("expected ETag header")
+		}
+
+		req = httptest.NewRequest(http.MethodGet, "/__stage/env.js", nil)
+		req.Header.Set("If-None-Match", etag)
+		w = httptest.NewRecorder()
+		srv.router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusNotModified {
+			t.Errorf("expected status 304, got %d", w.Code)
+		}
+
+		if w.Body.Len() != 0 {
+			t.Errorf("expected empty body, got %s", w.Body.String())
+		}
+	})
+}
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/cb-demos/stage/internal/config"
+	"github.com/cb-demos/stage/internal/transformer"
+)
+
+// site is what the server serves for one host: its configuration, with the
+// host's asset directory and replacements, the files transformed for it and
+// the runtime env rendered from its replacements
+type site struct {
+	host          string // name of the virtual host, empty for the default site
+	config        *config.Config
+	cache         *transformer.Cache
+	runtimeEnv    *runtimeEnv
+	requestValues map[string]config.RequestValue // by placeholder name
+}
+
+// newSite creates the site serving cache with the settings of cfg
+func newSite(cfg *config.Config, cache *transformer.Cache) *site {
+	s := &site{
+		config:        cfg,
+		cache:         cache,
+		runtimeEnv:    newRuntimeEnv(cfg.Replacements, cfg.RuntimeEnvAllowlist),
+		requestValues: make(map[string]config.RequestValue, len(cfg.RequestValues)),
+	}
+	for _, v := range cfg.RequestValues {
+		s.requestValues[v.Name] = v
+	}
+	return s
+}
+
+// sites are the default site and those of the virtual hosts, replaced as a
+// whole on reload so each request sees a single configuration
+type sites struct {
+	site  *site            // served to hosts without a site of their own
+	hosts map[string]*site // by host name
+}
+
+// newSites creates the sites of cfg, with cache for the default site and
+// hostCaches for its virtual hosts by name. Hosts without a cache are left
+// out.
+func newSites(cfg *config.Config, cache *transformer.Cache, hostCaches map[string]*transformer.Cache) *sites {
+	result := &sites{
+		site:  newSite(cfg, cache),
+		hosts: make(map[string]*site, len(hostCaches)),
+	}
+	for _, host := range cfg.Hosts {
+		if hostCache, ok := hostCaches[host.Name]; ok {
+			hostSite := newSite(cfg.ForHost(host), hostCache)
+			hostSite.host = host.Name
+			result.hosts[host.Name] = hostSite
+		}
+	}
+	return result
+}
+
+// Swap replaces everything the server serves with the settings of cfg, cache
+// for the default site and hostCaches for the virtual hosts of cfg by name.
+// Requests already being served finish with what they started with. The
+// listen address and Prometheus settings only change on restart.
+func (s *Server) Swap(cfg *config.Config, cache *transformer.Cache, hostCaches map[string]*transformer.Cache) {
+	s.sites.Store(newSites(cfg, cache, hostCaches))
+}
+
+// siteFor returns the site for the host a request was sent to, or the
+// default site for hosts that have none
+func (s *Server) siteFor(r *http.Request) *site {
+	current := s.sites.Load()
+	if site, ok := current.hosts[requestHost(r)]; ok {
+		return site
+	}
+	return current.site
+}
@@ -0,0 +1,116 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cb-demos/stage/internal/config"
+	"github.com/cb-demos/stage/internal/transformer"
+)
+
+func TestVirtualHosts(t *testing.T) {
+	defaultDir := t.TempDir()
+	demoDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(demoDir, "logo.svg"), []byte("<svg/>"), 0644); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+
+	cfg := &config.Config{
+		Port:                "8080",
+		Profile:             "prod",
+		AssetDir:            defaultDir,
+		Host:                "0.0.0.0",
+		Replacements:        map[string]string{"API_URL": "http://localhost"},
+		RuntimeEnvAllowlist: []string{"API_URL"},
+	}
+	cache := transformer.NewCache()
+	cache.Set("index.html", []byte("default"))
+
+	qa := config.VirtualHost{
+		Name:         "qa.example.com",
+		AssetDir:     defaultDir,
+		Replacements: map[string]string{"API_URL": "https://api.qa.example.com"},
+	}
+	qaCache := transformer.NewCache()
+	qaCache.Set("index.html", []byte("qa"))
+
+	demo := config.VirtualHost{
+		Name:         "demo.example.com",
+		AssetDir:     demoDir,
+		Profile:      "demo",
+		Replacements: map[string]string{"API_URL": "https://api.demo.example.com"},
+	}
+	demoCache := transformer.NewCache()
+	demoCache.Set("index.html", []byte("demo"))
+	cfg.Hosts = []config.VirtualHost{qa, demo}
+
+	srv := New(cfg, cache, testLogger())
+	srv.Swap(cfg, cache, map[string]*transformer.Cache{qa.Name: qaCache, demo.Name: demoCache})
+
+	tests := []struct {
+		name     string
+		host     string
+		path     string
+		expected string
+	}{
+		{"default host", "localhost:8080", "/index.html", "default"},
+		{"unknown host", "other.example.com", "/index.html", "default"},
+		{"qa", "qa.example.com", "/index.html", "qa"},
+		{"host with port", "QA.example.com:8080", "/index.html", "qa"},
+		{"spa route", "demo.example.com", "/dashboard", "demo"},
+		{"host asset directory", "demo.example.com", "/logo.svg", "<svg/>"},
+		{"runtime env", "qa.example.com", "/__stage/env.json", `{"API_URL":"https://api.qa.example.com"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.Host = tt.host
+			w := httptest.NewRecorder()
+			srv.router.ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d", w.Code)
+			}
+			if w.Body.String() != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, w.Body.String())
+			}
+		})
+	}
+
+	t.Run("asset outside host directory", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/logo.svg", nil)
+		req.Host = "qa.example.com"
+		w := httptest.NewRecorder()
+		srv.router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusNotFound {
+			t.Errorf("expected status 404 for a file of another host, got %d", w.Code)
+		}
+	})
+
+	t.Run("health", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/health", nil)
+		req.Host = "demo.example.com"
+		w := httptest.NewRecorder()
+		srv.router.ServeHTTP(w, req)
+
+		var response map[string]interface{}
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("failed to parse response: %v", err)
+		}
+		if response["hosts"] != float64(2) {
+			t.Errorf("expected 2 hosts, got %v", response["hosts"])
+		}
+		if response["host"] != "demo.example.com" || response["profile"] != "demo" {
+			t.Errorf("expected the host and profile of the demo site, got %v %v", response["host"], response["profile"])
+		}
+		if response["cache_bytes"] != float64(len("demo")) {
+			t.Errorf("expected the stats of the demo cache, got %v bytes", response["cache_bytes"])
+		}
+	})
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"crypto/subtle"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ReloadFunc re-reads the configuration, transforms the assets again into
+// fresh caches and hands them to Swap. On error the server keeps serving
+// what it had.
+type ReloadFunc func() error
+
+// errNoReloader is returned by Reload when no ReloadFunc is set
+var errNoReloader = errors.New("reloading is not supported")
+
+// SetReloader sets the function run by Reload
+func (s *Server) SetReloader(reload ReloadFunc) {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+	s.reload = reload
+}
+
+// Reload runs the reload function, one reload at a time, and logs the
+// outcome. Requests keep being served from the current caches meanwhile.
+func (s *Server) Reload() error {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+
+	if s.reload == nil {
+		return errNoReloader
+	}
+
+	start := time.Now()
+	if err := s.reload(); err != nil {
+		slog.Error("Reload failed, still serving the previous configuration", "error", err)
+		return err
+	}
+
+	reloads := atomic.AddUint64(&s.configReloads, 1)
+	slog.Info("Configuration reloaded", "configReloads", reloads, "duration", time.Since(start))
+	return nil
+}
+
+// handleReload reloads the configuration for clients presenting the reload
+// token as a bearer token. Without a token configured the endpoint does not
+// exist.
+func (s *Server) handleReload(c *gin.Context) {
+	token := s.sites.Load().site.config.ReloadToken
+	if token == "" {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "not found",
+			"path":  c.Request.URL.Path,
+		})
+		return
+	}
+
+	if !validToken(c.GetHeader("Authorization"), token) {
+		c.Header("WWW-Authenticate", `Bearer realm="stage"`)
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	if err := s.Reload(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":  "reload failed",
+			"detail": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":         "reloaded",
+		"config_reloads": atomic.LoadUint64(&s.configReloads),
+	})
+}
+
+// validToken reports whether an Authorization header carries token as a
+// bearer token, in constant time
+func validToken(authorization, token string) bool {
+	scheme, credentials, ok := strings.Cut(authorization, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return false
+	}
+	credentials = strings.TrimSpace(credentials)
+	return subtle.ConstantTimeCompare([]byte(credentials), []byte(token)) == 1
+}
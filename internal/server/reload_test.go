@@ -0,0 +1,178 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/cb-demos/stage/internal/config"
+	"github.com/cb-demos/stage/internal/transformer"
+)
+
+func TestReloadEndpoint(t *testing.T) {
+	tempDir := t.TempDir()
+
+	cfg := &config.Config{
+		Port:         "8080",
+		AssetDir:     tempDir,
+		Host:         "0.0.0.0",
+		Replacements: map[string]string{},
+		ReloadToken:  "s3cret",
+	}
+	cache := transformer.NewCache()
+	cache.Set("index.html", []byte("v1"))
+
+	srv := New(cfg, cache, testLogger())
+
+	var fail bool
+	srv.SetReloader(func() error {
+		if fail {
+			return errors.New("invalid config")
+		}
+		next := transformer.NewCache()
+		next.Set("index.html", []byte("v2"))
+		srv.Swap(cfg, next, nil)
+		return nil
+	})
+
+	get := func() string {
+		req := httptest.NewRequest(http.MethodGet, "/index.html", nil)
+		w := httptest.NewRecorder()
+		srv.router.ServeHTTP(w, req)
+		return w.Body.String()
+	}
+	reload := func(authorization string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/__stage/reload", nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		w := httptest.NewRecorder()
+		srv.router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("missing token", func(t *testing.T) {
+		w := reload("")
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("expected status 401, got %d", w.Code)
+		}
+		if w.Header().Get("WWW-Authenticate") == "" {
+			t.Error("expected a WWW-Authenticate header")
+		}
+	})
+
+	t.Run("wrong token", func(t *testing.T) {
+		if w := reload("Bearer guess"); w.Code != http.StatusUnauthorized {
+			t.Errorf("expected status 401, got %d", w.Code)
+		}
+		if body := get(); body != "v1" {
+			t.Errorf("expected the cache to be unchanged, got %q", body)
+		}
+	})
+
+	t.Run("failed reload", func(t *testing.T) {
+		fail = true
+		defer func() { fail = false }()
+
+		w := reload("Bearer s3cret")
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("expected status 500, got %d", w.Code)
+		}
+		if body := get(); body != "v1" {
+			t.Errorf("expected the previous cache to be kept, got %q", body)
+		}
+	})
+
+	t.Run("reload", func(t *testing.T) {
+		w := reload("bearer s3cret")
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+
+		var response map[string]interface{}
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("failed to parse response: %v", err)
+		}
+		if response["config_reloads"] != float64(1) {
+			t.Errorf("expected 1 config reload, got %v", response["config_reloads"])
+		}
+		if body := get(); body != "v2" {
+			t.Errorf("expected the new cache to be served, got %q", body)
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		disabled := New(&config.Config{Port: "8080", AssetDir: tempDir, Replacements: map[string]string{}},
+			transformer.NewCache(), testLogger())
+
+		req := httptest.NewRequest(http.MethodPost, "/__stage/reload", nil)
+		req.Header.Set("Authorization", "Bearer ")
+		w := httptest.NewRecorder()
+		disabled.router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusNotFound {
+			t.Errorf("expected status 404 without a reload token, got %d", w.Code)
+		}
+	})
+}
+
+func TestReloadWithoutReloader(t *testing.T) {
+	cfg := &config.Config{Port: "8080", AssetDir: t.TempDir(), Replacements: map[string]string{}}
+	srv := New(cfg, transformer.NewCache(), testLogger())
+
+	if err := srv.Reload(); err == nil {
+		t.Error("expected an error without a reload function")
+	}
+}
+
+func TestSwapDuringRequests(t *testing.T) {
+	qa := config.VirtualHost{Name: "qa.example.com", AssetDir: t.TempDir()}
+	cfg := &config.Config{
+		Port:         "8080",
+		AssetDir:     qa.AssetDir,
+		Replacements: map[string]string{},
+		Hosts:        []config.VirtualHost{qa},
+	}
+
+	newCaches := func(version string) (*transformer.Cache, map[string]*transformer.Cache) {
+		cache := transformer.NewCache()
+		cache.Set("index.html", []byte("default "+version))
+		qaCache := transformer.NewCache()
+		qaCache.Set("index.html", []byte("qa "+version))
+		return cache, map[string]*transformer.Cache{qa.Name: qaCache}
+	}
+
+	cache, hostCaches := newCaches("v0")
+	srv := New(cfg, cache, testLogger())
+	srv.Swap(cfg, cache, hostCaches)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				req := httptest.NewRequest(http.MethodGet, "/index.html", nil)
+				req.Host = qa.Name
+				w := httptest.NewRecorder()
+				srv.router.ServeHTTP(w, req)
+
+				if w.Code != http.StatusOK || !strings.HasPrefix(w.Body.String(), "qa ") {
+					t.Errorf("expected the qa page during swaps, got %d %q", w.Code, w.Body.String())
+					return
+				}
+			}
+		}()
+	}
+
+	for i := 1; i <= 50; i++ {
+		cache, hostCaches := newCaches(fmt.Sprintf("v%d", i))
+		srv.Swap(cfg, cache, hostCaches)
+	}
+	wg.Wait()
+}
@@ -8,6 +8,8 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/cb-demos/stage/internal/config"
 	"github.com/cb-demos/stage/internal/prometheus"
@@ -18,11 +20,17 @@ import (
 // Server represents the HTTP server
 type Server struct {
 	router           *gin.Engine
-	config           *config.Config
-	cache            *transformer.Cache
+	config           *config.Config // as loaded at startup; listen address and Prometheus settings
+	sites            atomic.Pointer[sites]
 	httpServer       *http.Server
 	prometheusMock   *prometheus.MockServer
 	prometheusHandler *prometheus.Handler
+
+	// reload re-reads the configuration and swaps in the result; reloadMu
+	// runs one reload at a time
+	reload        ReloadFunc
+	reloadMu      sync.Mutex
+	configReloads uint64 // successful reloads
 }
 
 // New creates a new Server instance
@@ -39,8 +47,8 @@ func New(cfg *config.Config, cache *transformer.Cache, logger *slog.Logger) *Ser
 	s := &Server{
 		router: router,
 		config: cfg,
-		cache:  cache,
 	}
+	s.sites.Store(newSites(cfg, cache, nil))
 
 	// Initialize Prometheus mock server if enabled
 	if cfg.PrometheusEnabled {
@@ -60,6 +68,15 @@ func (s *Server) setupRoutes() {
 	// Health check endpoint
 	s.router.GET("/health", s.handleHealth)
 
+	// Stage introspection endpoints
+	s.router.GET("/__stage/unresolved", s.handleUnresolved)
+	s.router.GET("/__stage/usage", s.handleUsage)
+	s.router.POST("/__stage/reload", s.handleReload)
+
+	// Runtime config for apps that read window.__ENV__ instead of placeholders
+	s.router.GET("/__stage/env.js", s.handleEnvJS)
+	s.router.GET("/__stage/env.json", s.handleEnvJSON)
+
 	// Prometheus mock server routes (if enabled)
 	if s.prometheusHandler != nil {
 		// Prometheus API endpoints
@@ -81,20 +98,49 @@ func (s *Server) setupRoutes() {
 	s.router.NoRoute(s.handleAssets)
 }
 
-// handleHealth returns server health status
+// handleHealth returns server health status, with the profile and cache
+// stats of the requested host
 func (s *Server) handleHealth(c *gin.Context) {
-	hits, misses, sizeBytes := s.cache.Stats()
+	site := s.siteFor(c.Request)
+	stats := site.cache.Stats()
+	c.JSON(http.StatusOK, gin.H{
+		"status":          "ok",
+		"host":            site.host,
+		"profile":         site.config.Profile,
+		"hosts":           len(s.sites.Load().hosts),
+		"cache_files":     stats.Files,
+		"cache_resident":  stats.Resident,
+		"cache_bytes":     stats.SizeBytes,
+		"cache_max_bytes": stats.MaxBytes,
+		"cache_hits":      stats.Hits,
+		"cache_misses":    stats.Misses,
+		"cache_evictions": stats.Evictions,
+		"cache_loads":     stats.Loads,
+		"reloads":         site.cache.Reloads(),
+		"config_reloads":  atomic.LoadUint64(&s.configReloads),
+	})
+}
+
+// handleUnresolved lists placeholders left in transformed files
+func (s *Server) handleUnresolved(c *gin.Context) {
+	unresolved := s.siteFor(c.Request).cache.Unresolved()
 	c.JSON(http.StatusOK, gin.H{
-		"status":       "ok",
-		"cache_files":  s.cache.Size(),
-		"cache_bytes":  sizeBytes,
-		"cache_hits":   hits,
-		"cache_misses": misses,
+		"count":        len(unresolved),
+		"placeholders": unresolved,
 	})
 }
 
-// handleAssets serves static assets with transformation support
+// handleUsage reports which files each placeholder was found in, and which
+// replacements matched nothing
+func (s *Server) handleUsage(c *gin.Context) {
+	site := s.siteFor(c.Request)
+	c.JSON(http.StatusOK, site.cache.UsageReport(site.config.Replacements, site.config.RuntimeEnvAllowlist))
+}
+
+// handleAssets serves static assets with transformation support from the
+// site of the requested host
 func (s *Server) handleAssets(c *gin.Context) {
+	site := s.siteFor(c.Request)
 	requestPath := c.Request.URL.Path
 
 	// Remove leading slash for file system operations
@@ -104,17 +150,17 @@ func (s *Server) handleAssets(c *gin.Context) {
 	cleanPath = filepath.Clean(cleanPath)
 
 	// Try to serve from cache first
-	if content, exists := s.cache.Get(cleanPath); exists {
+	if entry, exists := site.cache.GetEntry(cleanPath); exists {
 		slog.Debug("Serving from cache", "path", requestPath)
-		s.serveContent(c, cleanPath, content)
+		site.serveContent(c, cleanPath, entry)
 		return
 	}
 
 	// Build full file system path
-	fullPath := filepath.Join(s.config.AssetDir, cleanPath)
+	fullPath := filepath.Join(site.config.AssetDir, cleanPath)
 
 	// Verify the resolved path is within the asset directory (defense in depth)
-	absAssetDir, err := filepath.Abs(s.config.AssetDir)
+	absAssetDir, err := filepath.Abs(site.config.AssetDir)
 	if err != nil {
 		slog.Error("Failed to resolve asset directory", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -145,6 +191,7 @@ func (s *Server) handleAssets(c *gin.Context) {
 	if err == nil && !fileInfo.IsDir() {
 		// File exists but not in cache (e.g., images, fonts)
 		slog.Debug("Serving original file", "path", requestPath)
+		site.setCacheHeaders(c, filepath.ToSlash(cleanPath), false)
 		c.File(fullPath)
 		return
 	}
@@ -154,16 +201,17 @@ func (s *Server) handleAssets(c *gin.Context) {
 		indexPath := "index.html"
 
 		// Try cached index.html first
-		if content, exists := s.cache.Get(indexPath); exists {
+		if entry, exists := site.cache.GetEntry(indexPath); exists {
 			slog.Debug("Serving index.html from cache for SPA route", "requestPath", requestPath)
-			s.serveContent(c, indexPath, content)
+			site.serveContent(c, indexPath, entry)
 			return
 		}
 
 		// Try original index.html
-		indexFullPath := filepath.Join(s.config.AssetDir, indexPath)
+		indexFullPath := filepath.Join(site.config.AssetDir, indexPath)
 		if _, err := os.Stat(indexFullPath); err == nil {
 			slog.Debug("Serving original index.html for SPA route", "requestPath", requestPath)
+			site.setCacheHeaders(c, indexPath, false)
 			c.File(indexFullPath)
 			return
 		}
@@ -176,10 +224,53 @@ func (s *Server) handleAssets(c *gin.Context) {
 	})
 }
 
-// serveContent serves content with appropriate content type
-func (s *Server) serveContent(c *gin.Context, path string, content []byte) {
+// serveContent serves a cached entry with appropriate content type, using a
+// precompressed variant when the client accepts it and answering conditional
+// requests with 304 Not Modified. Entries with per-response values are
+// rendered for each request.
+func (s *site) serveContent(c *gin.Context, path string, entry *transformer.Entry) {
+	if entry.Template != nil {
+		s.serveTemplate(c, path, entry.Template)
+		return
+	}
+
 	// Determine content type based on file extension
 	contentType := getContentType(path)
+
+	content, encoding := entry.Content, ""
+	if entry.Gzip != nil || entry.Brotli != nil {
+		// Shared caches must key on Accept-Encoding once variants exist
+		c.Header("Vary", "Accept-Encoding")
+
+		encoding = negotiateEncoding(c.GetHeader("Accept-Encoding"), entry)
+		switch encoding {
+		case "br":
+			content = entry.Brotli
+		case "gzip":
+			content = entry.Gzip
+		}
+	}
+
+	s.setCacheHeaders(c, path, entry.Transformed)
+
+	// Validators let browsers revalidate instead of refetching in full
+	etag := variantETag(entry.ETag, encoding)
+	if etag != "" {
+		c.Header("ETag", etag)
+	}
+	if !entry.ModTime.IsZero() {
+		c.Header("Last-Modified", entry.ModTime.UTC().Format(http.TimeFormat))
+	}
+
+	if notModified(c.Request, etag, entry.ModTime) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	if encoding != "" {
+		c.Header("Content-Encoding", encoding)
+	}
+
 	c.Data(http.StatusOK, contentType, content)
 }
 
@@ -221,6 +312,7 @@ func getContentType(path string) string {
 		".js":   "application/javascript; charset=utf-8",
 		".mjs":  "application/javascript; charset=utf-8",
 		".json": "application/json; charset=utf-8",
+		".map":  "application/json; charset=utf-8",
 		".xml":  "application/xml; charset=utf-8",
 		".svg":  "image/svg+xml",
 		".png":  "image/png",
@@ -257,6 +349,7 @@ func shouldFallbackToSPA(path string) bool {
 		"/metrics",
 		"/health",
 		"/prometheus/",
+		"/__stage/",
 	}
 	for _, sp := range specialPaths {
 		if strings.HasPrefix(path, sp) {
@@ -29,6 +29,7 @@ func TestHealthEndpoint(t *testing.T) {
 		AssetDir:     tempDir,
 		Host:         "0.0.0.0",
 		Replacements: map[string]string{},
+		Profile:      "qa",
 	}
 
 	cache := transformer.NewCache()
@@ -54,6 +55,10 @@ func TestHealthEndpoint(t *testing.T) {
 		t.Errorf("expected status 'ok', got %v", response["status"])
 	}
 
+	if response["profile"] != "qa" {
+		t.Errorf("expected profile 'qa', got %v", response["profile"])
+	}
+
 	// Cache files should be 1 since we added one item
 	if cacheFiles, ok := response["cache_files"].(float64); !ok || int(cacheFiles) != 1 {
 		t.Errorf("expected cache_files 1, got %v", response["cache_files"])
@@ -72,6 +77,10 @@ func TestHealthEndpoint(t *testing.T) {
 	if _, ok := response["cache_misses"]; !ok {
 		t.Error("expected cache_misses field in response")
 	}
+
+	if reloads, ok := response["reloads"].(float64); !ok || reloads != 0 {
+		t.Errorf("expected reloads 0, got %v", response["reloads"])
+	}
 }
 
 func TestServeCachedAsset(t *testing.T) {
@@ -474,3 +483,107 @@ func TestPathTraversalPrevention(t *testing.T) {
 		})
 	}
 }
+
+func TestUnresolvedEndpoint(t *testing.T) {
+	tempDir := t.TempDir()
+
+	cfg := &config.Config{
+		Port:         "8080",
+		AssetDir:     tempDir,
+		Host:         "0.0.0.0",
+		Replacements: map[string]string{},
+	}
+
+	cache := transformer.NewCache()
+	cache.SetEntry("app.js", &transformer.Entry{
+		Content: []byte("const key = '__FF_SDK_KEY__';"),
+		Unresolved: []transformer.Unresolved{
+			{File: "app.js", Line: 1, Token: "__FF_SDK_KEY__"},
+		},
+	})
+	cache.Set("index.html", []byte("<html></html>"))
+
+	srv := New(cfg, cache, testLogger())
+
+	req := httptest.NewRequest(http.MethodGet, "/__stage/unresolved", nil)
+	w := httptest.NewRecorder()
+
+	srv.router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+
+	var response struct {
+		Count        int                      `json:"count"`
+		Placeholders []transformer.Unresolved `json:"placeholders"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+
+	if response.Count != 1 || len(response.Placeholders) != 1 {
+		t.Fatalf("expected 1 unresolved placeholder, got %+v", response)
+	}
+
+	if response.Placeholders[0].Token != "__FF_SDK_KEY__" {
+		t.Errorf("expected token __FF_SDK_KEY__, got %s", response.Placeholders[0].Token)
+	}
+}
+
+func TestUsageEndpoint(t *testing.T) {
+	tempDir := t.TempDir()
+
+	cfg := &config.Config{
+		Port:     "8080",
+		AssetDir: tempDir,
+		Host:     "0.0.0.0",
+		Replacements: map[string]string{
+			"API_ENDPOINT": "https://api.example.com",
+			"UNUSED":       "value",
+		},
+	}
+
+	cache := transformer.NewCache()
+	cache.SetEntry("app.js", &transformer.Entry{
+		Content:      []byte("fetch('https://api.example.com')"),
+		Placeholders: map[string]int{"API_ENDPOINT": 2, "TITLE": 1},
+	})
+	cache.SetEntry("index.html", &transformer.Entry{
+		Content:      []byte("<html></html>"),
+		Placeholders: map[string]int{"API_ENDPOINT": 1},
+	})
+
+	srv := New(cfg, cache, testLogger())
+
+	req := httptest.NewRequest(http.MethodGet, "/__stage/usage", nil)
+	w := httptest.NewRecorder()
+
+	srv.router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+
+	var response transformer.UsageReport
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+
+	if len(response.Placeholders) != 2 {
+		t.Fatalf("expected 2 placeholders, got %+v", response.Placeholders)
+	}
+
+	api := response.Placeholders[0]
+	if api.Name != "API_ENDPOINT" || !api.Configured || api.Occurrences != 3 || len(api.Files) != 2 {
+		t.Errorf("unexpected usage for API_ENDPOINT: %+v", api)
+	}
+
+	if title := response.Placeholders[1]; title.Name != "TITLE" || title.Configured {
+		t.Errorf("expected TITLE to be found but not configured, got %+v", title)
+	}
+
+	if len(response.Unused) != 1 || response.Unused[0] != "UNUSED" {
+		t.Errorf("expected UNUSED to be reported as unused, got %v", response.Unused)
+	}
+}
@@ -0,0 +1,122 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cb-demos/stage/internal/config"
+	"github.com/cb-demos/stage/internal/transformer"
+	"github.com/gin-gonic/gin"
+)
+
+// nonceBytes is the size of a nonce before encoding; CSP asks for at least
+// 128 bits
+const nonceBytes = 16
+
+// serveTemplate renders an entry for this response, with a fresh CSP nonce
+// and the values taken from the request. Rendered content is not compressed.
+// Responses with a nonce differ every time, so they are neither revalidated
+// nor stored; others vary with the request values they use.
+func (s *site) serveTemplate(c *gin.Context, path string, tpl *transformer.Template) {
+	values := make(map[string]string)
+	for _, name := range tpl.Names() {
+		v, ok := s.requestValues[name]
+		if !ok {
+			continue
+		}
+		switch v.Source {
+		case config.RequestHeader:
+			c.Writer.Header().Add("Vary", v.Key)
+		case config.RequestCookie:
+			c.Writer.Header().Add("Vary", "Cookie")
+		}
+		values[name] = requestValue(c, v)
+	}
+
+	if !tpl.Nonce() {
+		content := tpl.Execute("", values)
+		s.setCacheHeaders(c, path, true)
+
+		etag := transformer.ETag(content)
+		c.Header("ETag", etag)
+		if notModified(c.Request, etag, time.Time{}) {
+			c.Status(http.StatusNotModified)
+			return
+		}
+
+		c.Data(http.StatusOK, getContentType(path), content)
+		return
+	}
+
+	nonce, err := newNonce()
+	if err != nil {
+		slog.Error("Failed to generate CSP nonce", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "internal server error",
+		})
+		return
+	}
+
+	c.Header("Content-Security-Policy", strings.ReplaceAll(s.config.CSPPolicy, "{nonce}", nonce))
+	c.Header("Cache-Control", "no-store")
+
+	c.Data(http.StatusOK, getContentType(path), tpl.Execute(nonce, values))
+}
+
+// newNonce returns a random base64 nonce
+func newNonce() (string, error) {
+	b := make([]byte, nonceBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+// requestValue returns the value v takes from the request, or "" when the
+// request does not have it
+func requestValue(c *gin.Context, v config.RequestValue) string {
+	switch v.Source {
+	case config.RequestHeader:
+		return c.GetHeader(v.Key)
+	case config.RequestCookie:
+		value, _ := c.Cookie(v.Key)
+		return value
+	case config.RequestHost:
+		return requestHost(c.Request)
+	case config.RequestSubdomain:
+		return subdomain(requestHost(c.Request))
+	}
+	return ""
+}
+
+// requestHost returns the lowercased host of a request, without port
+func requestHost(r *http.Request) string {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(strings.Trim(host, "[]"))
+}
+
+// subdomain returns the first label of host when it is below a registered
+// domain, e.g. "acme" for acme.example.com or acme.localhost. IP addresses
+// and bare domains have none.
+func subdomain(host string) string {
+	if net.ParseIP(host) != nil {
+		return ""
+	}
+
+	labels := strings.Split(strings.TrimSuffix(host, "."), ".")
+	switch {
+	case len(labels) >= 3:
+		return labels[0]
+	case len(labels) == 2 && labels[1] == "localhost":
+		return labels[0]
+	}
+	return ""
+}
@@ -0,0 +1,193 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/cb-demos/stage/internal/config"
+	"github.com/cb-demos/stage/internal/transformer"
+)
+
+// templateServer transforms files into a new asset directory with opts and
+// returns a server for them
+func templateServer(t *testing.T, cfg *config.Config, files map[string]string, opts ...transformer.Option) *Server {
+	t.Helper()
+	cfg.AssetDir = t.TempDir()
+	cfg.Replacements = map[string]string{}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(cfg.AssetDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to create file: %v", err)
+		}
+	}
+
+	trans := transformer.New(cfg.AssetDir, cfg.Replacements, append(opts, transformer.WithCompression(true))...)
+	if err := trans.TransformAll(); err != nil {
+		t.Fatalf("TransformAll failed: %v", err)
+	}
+	return New(cfg, trans.GetCache(), testLogger())
+}
+
+func TestServeWithNonce(t *testing.T) {
+	cfg := &config.Config{
+		CSPNonce:  true,
+		CSPPolicy: config.DefaultCSPPolicy,
+	}
+	srv := templateServer(t, cfg, map[string]string{
+		"index.html": `<script>x()</script>`,
+		"app.js":     "x()",
+	}, transformer.WithCSPNonce(true))
+
+	nonceAttr := regexp.MustCompile(`nonce="([^"]+)"`)
+	var nonces []string
+	for _, path := range []string{"/index.html", "/dashboard"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Accept-Encoding", "gzip, br")
+		w := httptest.NewRecorder()
+		srv.router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected status 200, got %d", path, w.Code)
+		}
+
+		match := nonceAttr.FindStringSubmatch(w.Body.String())
+		if match == nil {
+			t.Fatalf("%s: expected a nonce in %s", path, w.Body.String())
+		}
+		nonce := match[1]
+		nonces = append(nonces, nonce)
+
+		csp := w.Header().Get("Content-Security-Policy")
+		if !strings.Contains(csp, "'nonce-"+nonce+"'") || strings.Contains(csp, "{nonce}") {
+			t.Errorf("%s: expected policy with nonce %s, got %q", path, nonce, csp)
+		}
+		if w.Header().Get("Cache-Control") != "no-store" {
+			t.Errorf("%s: expected no-store, got %q", path, w.Header().Get("Cache-Control"))
+		}
+		if w.Header().Get("ETag") != "" || w.Header().Get("Content-Encoding") != "" {
+			t.Errorf("%s: expected no validators or encoding, got %v", path, w.Header())
+		}
+	}
+
+	if nonces[0] == nonces[1] {
+		t.Error("expected a fresh nonce per response")
+	}
+
+	// Other files are served without a policy
+	req := httptest.NewRequest(http.MethodGet, "/app.js", nil)
+	w := httptest.NewRecorder()
+	srv.router.ServeHTTP(w, req)
+	if w.Header().Get("Content-Security-Policy") != "" {
+		t.Error("expected no policy for files without nonces")
+	}
+}
+
+func TestServeRequestValues(t *testing.T) {
+	cfg := &config.Config{
+		RequestValues: []config.RequestValue{
+			{Name: "TENANT", Source: config.RequestSubdomain},
+			{Name: "REGION", Source: config.RequestHeader, Key: "X-Region"},
+			{Name: "THEME", Source: config.RequestCookie, Key: "theme"},
+		},
+	}
+	srv := templateServer(t, cfg, map[string]string{
+		"index.html": `<h1>__TENANT__</h1><p>__REGION:-eu__</p><body class="__THEME__">`,
+		"app.js":     "x()",
+	}, transformer.WithRequestPlaceholders(cfg.RequestNames()...))
+
+	req := httptest.NewRequest(http.MethodGet, "/index.html", nil)
+	req.Host = "acme.demo.example.com:8080"
+	req.Header.Set("X-Region", "<us>")
+	req.Header.Set("Accept-Encoding", "gzip")
+	req.AddCookie(&http.Cookie{Name: "theme", Value: "dark"})
+	w := httptest.NewRecorder()
+	srv.router.ServeHTTP(w, req)
+
+	expected := `<h1>acme</h1><p>&lt;us&gt;</p><body class="dark">`
+	if w.Code != http.StatusOK || w.Body.String() != expected {
+		t.Fatalf("expected %s, got %d %s", expected, w.Code, w.Body.String())
+	}
+	if vary := w.Header().Values("Vary"); strings.Join(vary, ",") != "X-Region,Cookie" {
+		t.Errorf("expected Vary on X-Region and Cookie, got %v", vary)
+	}
+	if w.Header().Get("Content-Encoding") != "" || w.Header().Get("Content-Security-Policy") != "" {
+		t.Errorf("expected no encoding or policy, got %v", w.Header())
+	}
+
+	// Same values revalidate; other values are a different representation
+	etag := w.Header().Get("ETag")
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	srv.router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotModified {
+		t.Errorf("expected 304 for the same values, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/index.html", nil)
+	req.Host = "localhost:8080"
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	srv.router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Body.String() != `<h1></h1><p>eu</p><body class="">` {
+		t.Errorf("expected defaults and empty values, got %d %s", w.Code, w.Body.String())
+	}
+
+	// Files without request placeholders are served as cached
+	req = httptest.NewRequest(http.MethodGet, "/app.js", nil)
+	req.Header.Set("X-Region", "us")
+	w = httptest.NewRecorder()
+	srv.router.ServeHTTP(w, req)
+	if vary := w.Header().Values("Vary"); len(vary) != 0 {
+		t.Errorf("expected no Vary for files without request values, got %v", vary)
+	}
+}
+
+func TestSubdomain(t *testing.T) {
+	tests := []struct {
+		host     string
+		expected string
+	}{
+		{"acme.example.com", "acme"},
+		{"acme.eu.example.com", "acme"},
+		{"example.com", ""},
+		{"acme.localhost", "acme"},
+		{"localhost", ""},
+		{"127.0.0.1", ""},
+		{"::1", ""},
+		{"acme.example.com.", "acme"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			if got := subdomain(tt.host); got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestRequestHost(t *testing.T) {
+	tests := []struct {
+		host     string
+		expected string
+	}{
+		{"Acme.Example.com", "acme.example.com"},
+		{"acme.example.com:8080", "acme.example.com"},
+		{"[::1]:8080", "::1"},
+		{"[::1]", "::1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Host = tt.host
+			if got := requestHost(req); got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
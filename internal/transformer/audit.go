@@ -0,0 +1,58 @@
+package transformer
+
+import (
+	"bytes"
+	"regexp"
+)
+
+// Unresolved describes a placeholder token left in transformed output
+type Unresolved struct {
+	File  string `json:"file"`
+	Line  int    `json:"line"`
+	Token string `json:"token"`
+}
+
+// DefaultIgnoredPlaceholders are well-known globals injected by frameworks
+// and bundlers that look like placeholders but are never meant to be replaced
+var DefaultIgnoredPlaceholders = []string{
+	"DEV",
+	"REACT_DEVTOOLS_GLOBAL_HOOK",
+	"NEXT_DATA",
+	"VUE_OPTIONS_API",
+	"VUE_PROD_DEVTOOLS",
+	"VUE_PROD_HYDRATION_MISMATCH_DETAILS",
+	"ENV", // window.__ENV__, set by /__stage/env.js
+}
+
+// unresolvedPattern matches upper-case placeholder tokens between the given
+// delimiters, including ones carrying filters or a modifier. Lower-case names
+// such as __webpack_require__ are bundler internals and are not reported.
+func unresolvedPattern(open, close string) *regexp.Regexp {
+	return regexp.MustCompile(regexp.QuoteMeta(open) + `([A-Z][A-Z0-9_]*?)(?:\|[a-z0-9]+)*(?::[-?].*?)?` + regexp.QuoteMeta(close))
+}
+
+// findUnresolved scans transformed content for placeholder tokens matching
+// pattern that were not replaced, skipping ignored names
+func findUnresolved(pattern *regexp.Regexp, file string, content []byte, ignored map[string]bool) []Unresolved {
+	var result []Unresolved
+
+	line := 1
+	lastOffset := 0
+	for _, loc := range pattern.FindAllSubmatchIndex(content, -1) {
+		name := string(content[loc[2]:loc[3]])
+		if ignored[name] {
+			continue
+		}
+
+		line += bytes.Count(content[lastOffset:loc[0]], []byte("\n"))
+		lastOffset = loc[0]
+
+		result = append(result, Unresolved{
+			File:  file,
+			Line:  line,
+			Token: string(content[loc[0]:loc[1]]),
+		})
+	}
+
+	return result
+}
@@ -0,0 +1,110 @@
+package transformer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFindUnresolved(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		ignored  map[string]bool
+		expected []Unresolved
+	}{
+		{
+			name:     "no placeholders",
+			content:  "const key = 'abc';",
+			expected: nil,
+		},
+		{
+			name:    "bare placeholder with line numbers",
+			content: "line one\nconst key = '__FF_SDK_KEY__';\n\nconst url = '__API_ENDPOINT__';",
+			expected: []Unresolved{
+				{File: "app.js", Line: 2, Token: "__FF_SDK_KEY__"},
+				{File: "app.js", Line: 4, Token: "__API_ENDPOINT__"},
+			},
+		},
+		{
+			name:    "required placeholder",
+			content: "const key = '__FF_SDK_KEY:?sdk key__';",
+			expected: []Unresolved{
+				{File: "app.js", Line: 1, Token: "__FF_SDK_KEY:?sdk key__"},
+			},
+		},
+		{
+			name:     "lower-case bundler internals ignored",
+			content:  "__webpack_require__(1); exports.__esModule = true;",
+			expected: nil,
+		},
+		{
+			name:     "ignored names skipped",
+			content:  "if (__DEV__) {} const x = '__CUSTOM__';",
+			ignored:  map[string]bool{"DEV": true, "CUSTOM": true},
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := findUnresolved(unresolvedPattern("__", "__"), "app.js", []byte(tt.content), tt.ignored)
+
+			if len(result) != len(tt.expected) {
+				t.Fatalf("expected %d unresolved, got %d: %v", len(tt.expected), len(result), result)
+			}
+
+			for i, want := range tt.expected {
+				if result[i] != want {
+					t.Errorf("at %d expected %+v, got %+v", i, want, result[i])
+				}
+			}
+		})
+	}
+}
+
+func TestDefaultIgnoredPlaceholders(t *testing.T) {
+	tempDir := t.TempDir()
+	content := "const api = window.__ENV__.API_URL; if (__DEV__) {}"
+	if err := os.WriteFile(filepath.Join(tempDir, "app.js"), []byte(content), 0644); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+
+	trans := New(tempDir, map[string]string{})
+	if err := trans.TransformAll(); err != nil {
+		t.Fatalf("TransformAll failed: %v", err)
+	}
+	if unresolved := trans.GetCache().Unresolved(); len(unresolved) != 0 {
+		t.Errorf("expected well-known globals not to be reported, got %+v", unresolved)
+	}
+}
+
+func TestTransformAllRecordsUnresolved(t *testing.T) {
+	tempDir := t.TempDir()
+
+	files := map[string]string{
+		"index.html": "<html>__TITLE__</html>",
+		"app.js":     "const key = '__KEY__';\nconst other = '__MISSING__';\nif (__DEV__) {}",
+	}
+	for path, content := range files {
+		if err := os.WriteFile(filepath.Join(tempDir, path), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to create test file %s: %v", path, err)
+		}
+	}
+
+	trans := New(tempDir, map[string]string{"KEY": "value"}, WithIgnoredPlaceholders("TITLE"))
+	if err := trans.TransformAll(); err != nil {
+		t.Fatalf("TransformAll failed: %v", err)
+	}
+
+	unresolved := trans.GetCache().Unresolved()
+	expected := []Unresolved{{File: "app.js", Line: 2, Token: "__MISSING__"}}
+
+	if len(unresolved) != len(expected) {
+		t.Fatalf("expected %d unresolved, got %d: %v", len(expected), len(unresolved), unresolved)
+	}
+
+	if unresolved[0] != expected[0] {
+		t.Errorf("expected %+v, got %+v", expected[0], unresolved[0])
+	}
+}
@@ -0,0 +1,64 @@
+package transformer
+
+import (
+	"bytes"
+	"compress/gzip"
+
+	"github.com/andybalholm/brotli"
+)
+
+// compressMinSize is the smallest content worth compressing; below it the
+// encoding overhead outweighs the savings
+const compressMinSize = 1024
+
+// brotliLevel trades a slower startup for smaller bundles. Compression
+// happens once per file, so this is much higher than on-the-fly levels.
+const brotliLevel = 9
+
+// WithCompression enables precompressed gzip and brotli variants of every
+// cached file, produced once at transform time
+func WithCompression(enabled bool) Option {
+	return func(t *Transformer) {
+		t.compress = enabled
+	}
+}
+
+// precompress fills in the compressed variants of an entry served as cached,
+// if compression is enabled. Templates are rendered per response instead.
+func (t *Transformer) precompress(entry *Entry) {
+	if t.compress && entry.Template == nil {
+		compressEntry(entry)
+	}
+}
+
+// compressLoaded adds the compressed variants of an entry reloaded after
+// eviction, in place of the entry if it is still cached by then. The entry
+// itself is being served, so the variants go into a copy.
+func (t *Transformer) compressLoaded(relPath string, entry *Entry) {
+	compressed := *entry
+	t.precompress(&compressed)
+	if compressed.Gzip == nil && compressed.Brotli == nil {
+		return
+	}
+	t.cache.replaceEntry(relPath, entry, &compressed)
+}
+
+// compressEntry fills in the compressed variants of an entry. A variant is
+// only kept when it is actually smaller than the original content.
+func compressEntry(entry *Entry) {
+	if len(entry.Content) < compressMinSize {
+		return
+	}
+
+	var gz bytes.Buffer
+	gw, _ := gzip.NewWriterLevel(&gz, gzip.BestCompression)
+	if _, err := gw.Write(entry.Content); err == nil && gw.Close() == nil && gz.Len() < len(entry.Content) {
+		entry.Gzip = gz.Bytes()
+	}
+
+	var br bytes.Buffer
+	bw := brotli.NewWriterLevel(&br, brotliLevel)
+	if _, err := bw.Write(entry.Content); err == nil && bw.Close() == nil && br.Len() < len(entry.Content) {
+		entry.Brotli = br.Bytes()
+	}
+}
@@ -0,0 +1,76 @@
+package transformer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+func TestCompressEntry(t *testing.T) {
+	content := []byte(strings.Repeat("const endpoint = 'https://api.example.com';\n", 100))
+	entry := &Entry{Content: content}
+
+	compressEntry(entry)
+
+	if entry.Gzip == nil || entry.Brotli == nil {
+		t.Fatal("expected both variants for compressible content")
+	}
+
+	gr, err := gzip.NewReader(bytes.NewReader(entry.Gzip))
+	if err != nil {
+		t.Fatalf("invalid gzip variant: %v", err)
+	}
+	if decoded, _ := io.ReadAll(gr); !bytes.Equal(decoded, content) {
+		t.Error("gzip variant does not round-trip")
+	}
+
+	if decoded, _ := io.ReadAll(brotli.NewReader(bytes.NewReader(entry.Brotli))); !bytes.Equal(decoded, content) {
+		t.Error("brotli variant does not round-trip")
+	}
+
+	if entry.Size() != len(content)+len(entry.Gzip)+len(entry.Brotli) {
+		t.Errorf("unexpected entry size %d", entry.Size())
+	}
+}
+
+func TestCompressEntrySkipsSmallContent(t *testing.T) {
+	entry := &Entry{Content: []byte("tiny")}
+
+	compressEntry(entry)
+
+	if entry.Gzip != nil || entry.Brotli != nil {
+		t.Error("expected no variants for small content")
+	}
+}
+
+func TestTransformAllWithCompression(t *testing.T) {
+	tempDir := t.TempDir()
+
+	large := strings.Repeat("console.log('__KEY__');\n", 200)
+	os.WriteFile(filepath.Join(tempDir, "app.js"), []byte(large), 0644)
+
+	trans := New(tempDir, map[string]string{"KEY": "value"}, WithCompression(true))
+	if err := trans.TransformAll(); err != nil {
+		t.Fatalf("TransformAll failed: %v", err)
+	}
+
+	entry, exists := trans.GetCache().GetEntry("app.js")
+	if !exists {
+		t.Fatal("expected app.js to be cached")
+	}
+
+	if entry.Gzip == nil || entry.Brotli == nil {
+		t.Fatal("expected compressed variants")
+	}
+
+	decoded, _ := io.ReadAll(brotli.NewReader(bytes.NewReader(entry.Brotli)))
+	if !bytes.Equal(decoded, entry.Content) || strings.Contains(string(decoded), "__KEY__") {
+		t.Error("expected brotli variant of the transformed content")
+	}
+}
@@ -0,0 +1,356 @@
+package transformer
+
+import (
+	"bytes"
+	"sort"
+)
+
+// engine resolves placeholders in a single left-to-right pass over the
+// content, independent of how many replacements are configured.
+//
+// It is a multi-pattern matcher in the Aho-Corasick style: all placeholder
+// names are compiled into one trie, and every pattern shares the opening
+// delimiter as a common prefix. Because every match is anchored on that
+// delimiter, the automaton's failure transitions collapse to "resume at the
+// next delimiter", which bytes.Index finds with SIMD-accelerated search.
+// Replacement values are copied to the output and never rescanned, so a value
+// that itself contains a placeholder is inserted verbatim and the result does
+// not depend on map iteration order.
+type engine struct {
+	open  []byte
+	close []byte
+	root  *trieNode
+	raw   map[string]bool // names whose values are never escaped
+}
+
+// trieNode is a node in the placeholder name trie
+type trieNode struct {
+	children []trieEdge // few per node, so a linear scan beats a map
+	terminal bool       // a replacement name ends here
+	name     string
+	value    string
+	request  bool // the name is filled in per request
+}
+
+// trieEdge links a trie node to a child on one byte
+type trieEdge struct {
+	b    byte
+	next *trieNode
+}
+
+// token is a placeholder occurrence found in the content
+type token struct {
+	start, end int      // byte range of the whole token, delimiters included
+	name       string   // placeholder name without delimiters
+	filters    []string // value filters, in the order they apply
+	modifier   byte     // 0 for a bare placeholder, '-' (default) or '?' (required)
+	arg        string   // default value or required message
+	known      bool     // name has a configured replacement
+	value      string   // configured replacement, if known
+	request    bool     // name is filled in per request
+}
+
+// edit is a replacement made by the engine, in offsets of the original content
+type edit struct {
+	start, end int
+	value      string
+}
+
+// replaceResult is the outcome of a replace pass
+type replaceResult struct {
+	content []byte
+	missing []string       // required placeholders without a value, with their message
+	invalid []string       // placeholders using unknown filters, with the filter
+	edits   []edit         // replacements made, in content order
+	found   map[string]int // occurrences of each placeholder name, resolved or not
+	cuts    []cut          // request placeholders, left in the content
+}
+
+// newEngine compiles the replacement names into a matcher
+func newEngine(replacements map[string]string) *engine {
+	e := &engine{
+		open:  []byte("__"),
+		close: []byte("__"),
+		root:  &trieNode{},
+	}
+
+	// Insert in sorted order so the trie layout is deterministic
+	names := make([]string, 0, len(replacements))
+	for name := range replacements {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if name == "" {
+			continue
+		}
+		node := e.root
+		for i := 0; i < len(name); i++ {
+			node = node.child(name[i], true)
+		}
+		node.terminal = true
+		node.name = name
+		node.value = replacements[name]
+	}
+
+	return e
+}
+
+// addRequestNames adds names that are filled in per request. Their tokens
+// are left in the content and reported as cuts, taking precedence over
+// configured replacements of the same name.
+func (e *engine) addRequestNames(names []string) {
+	for _, name := range names {
+		if name == "" {
+			continue
+		}
+		node := e.root
+		for i := 0; i < len(name); i++ {
+			node = node.child(name[i], true)
+		}
+		node.terminal = true
+		node.name = name
+		node.request = true
+	}
+}
+
+// child returns the child for b, optionally creating it
+func (n *trieNode) child(b byte, create bool) *trieNode {
+	for _, edge := range n.children {
+		if edge.b == b {
+			return edge.next
+		}
+	}
+	if !create {
+		return nil
+	}
+	next := &trieNode{}
+	n.children = append(n.children, trieEdge{b: b, next: next})
+	return next
+}
+
+// replace resolves every placeholder in content. The result holds the
+// transformed content, the names (with their message, if any) of required
+// placeholders that have no value, placeholders with unknown filters, the
+// edits made and the request placeholders left in place. Content without any
+// replacement is returned as-is without copying.
+//
+// When tracker is not nil, configured values are escaped for the context the
+// placeholder appears in. Defaults written in the source are inserted as-is.
+func (e *engine) replace(content []byte, tracker contextTracker) replaceResult {
+	var out []byte
+	var result replaceResult
+
+	last := 0 // end of the content already copied to out
+	for pos := 0; pos < len(content); {
+		idx := bytes.Index(content[pos:], e.open)
+		if idx < 0 {
+			break
+		}
+		start := pos + idx
+
+		tok, ok := e.match(content, start)
+		if !ok {
+			// Delimiters may overlap (e.g. "___KEY__"), so retry one byte on
+			pos = start + 1
+			continue
+		}
+
+		if result.found == nil {
+			result.found = make(map[string]int)
+		}
+		result.found[tok.name]++
+
+		if name, ok := unknownFilter(tok.filters); ok {
+			result.invalid = append(result.invalid, tok.name+"|"+name)
+			pos = tok.end
+			continue
+		}
+
+		if tok.request {
+			c := cut{start: tok.start, end: tok.end, slot: slot{
+				name:     tok.name,
+				filters:  tok.filters,
+				modifier: tok.modifier,
+				arg:      tok.arg,
+			}}
+			if tracker != nil {
+				tracker.advance(content, tok.start)
+				c.slot.ctx = tracker.context()
+				tracker.skip(tok.end)
+			}
+			result.cuts = append(result.cuts, c)
+			pos = tok.end
+			continue
+		}
+
+		value, resolved := tok.resolve()
+		if !resolved {
+			if tok.modifier == '?' {
+				result.missing = append(result.missing, tok.describe())
+			}
+			pos = tok.end
+			continue
+		}
+		value = applyFilters(value, tok.filters)
+
+		if tracker != nil {
+			tracker.advance(content, tok.start)
+			ctx := tracker.context()
+			if tok.configured() && !e.raw[tok.name] && !preEncoded(tok.filters, ctx) {
+				value = escapeValue(ctx, value)
+			}
+			tracker.skip(tok.end)
+		}
+
+		if out == nil {
+			out = make([]byte, 0, len(content))
+		}
+		out = append(out, content[last:tok.start]...)
+		out = append(out, value...)
+		result.edits = append(result.edits, edit{start: tok.start, end: tok.end, value: value})
+		last = tok.end
+		pos = tok.end
+	}
+
+	if out == nil {
+		result.content = content
+	} else {
+		result.content = append(out, content[last:]...)
+	}
+	return result
+}
+
+// match parses the placeholder token starting at start, which points at an
+// opening delimiter. Known names are matched through the trie, preferring the
+// longest name that forms a valid token; unknown names are only recognised
+// when they carry a modifier, so their default can be applied.
+func (e *engine) match(content []byte, start int) (token, bool) {
+	nameStart := start + len(e.open)
+
+	// Collect every known name ending at a position that can close a token
+	var candidates []*trieNode
+	var ends []int
+	node := e.root
+	for p := nameStart; p < len(content); p++ {
+		if node = node.child(content[p], false); node == nil {
+			break
+		}
+		if node.terminal {
+			candidates = append(candidates, node)
+			ends = append(ends, p+1)
+		}
+	}
+
+	for i := len(candidates) - 1; i >= 0; i-- {
+		if tok, ok := e.parseSuffix(content, start, ends[i]); ok {
+			tok.name = candidates[i].name
+			tok.known = !candidates[i].request
+			tok.value = candidates[i].value
+			tok.request = candidates[i].request
+			return tok, true
+		}
+	}
+
+	// Unknown name: only a modifier makes it resolvable
+	p := nameStart
+	for p < len(content) && isNameByte(content[p]) {
+		p++
+	}
+	if p == nameStart {
+		return token{}, false
+	}
+
+	tok, ok := e.parseSuffix(content, start, p)
+	if !ok || tok.modifier == 0 {
+		return token{}, false
+	}
+	tok.name = string(content[nameStart:p])
+	return tok, true
+}
+
+// parseSuffix parses what follows a name ending at nameEnd: any "|filter"
+// names, then either the closing delimiter, or a ":-default" / ":?message"
+// modifier and then the closing delimiter. Modifier arguments cannot span
+// lines.
+func (e *engine) parseSuffix(content []byte, start, nameEnd int) (token, bool) {
+	var filterNames []string
+	for nameEnd < len(content) && content[nameEnd] == '|' {
+		p := nameEnd + 1
+		for p < len(content) && isFilterByte(content[p]) {
+			p++
+		}
+		if p == nameEnd+1 {
+			return token{}, false
+		}
+		filterNames = append(filterNames, string(content[nameEnd+1:p]))
+		nameEnd = p
+	}
+
+	rest := content[nameEnd:]
+
+	if bytes.HasPrefix(rest, e.close) {
+		return token{start: start, end: nameEnd + len(e.close), filters: filterNames}, true
+	}
+
+	if len(rest) < 2 || rest[0] != ':' || (rest[1] != '-' && rest[1] != '?') {
+		return token{}, false
+	}
+
+	argStart := nameEnd + 2
+	closeIdx := bytes.Index(content[argStart:], e.close)
+	if closeIdx < 0 {
+		return token{}, false
+	}
+	arg := content[argStart : argStart+closeIdx]
+	if bytes.IndexByte(arg, '\n') >= 0 {
+		return token{}, false
+	}
+
+	return token{
+		start:    start,
+		end:      argStart + closeIdx + len(e.close),
+		filters:  filterNames,
+		modifier: rest[1],
+		arg:      string(arg),
+	}, true
+}
+
+// resolve returns the text a token should be replaced with, or false if it
+// must be left in place
+func (tok token) resolve() (string, bool) {
+	switch tok.modifier {
+	case '-':
+		if tok.known && tok.value != "" {
+			return tok.value, true
+		}
+		return tok.arg, true
+	case '?':
+		if tok.known && tok.value != "" {
+			return tok.value, true
+		}
+		return "", false
+	default:
+		return tok.value, tok.known
+	}
+}
+
+// configured reports whether resolve uses the configured value rather than
+// the default written in the source
+func (tok token) configured() bool {
+	return tok.known && (tok.modifier == 0 || tok.value != "")
+}
+
+// describe names a token in error messages
+func (tok token) describe() string {
+	if tok.arg != "" {
+		return tok.name + " (" + tok.arg + ")"
+	}
+	return tok.name
+}
+
+// isNameByte reports whether b may appear in a placeholder name
+func isNameByte(b byte) bool {
+	return b == '_' || (b >= 'A' && b <= 'Z') || (b >= 'a' && b <= 'z') || (b >= '0' && b <= '9')
+}
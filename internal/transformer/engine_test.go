@@ -0,0 +1,262 @@
+package transformer
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEngineReplace(t *testing.T) {
+	tests := []struct {
+		name         string
+		content      string
+		replacements map[string]string
+		expected     string
+		missing      []string
+	}{
+		{
+			name:    "value containing another placeholder is not rescanned",
+			content: "__A__ __B__",
+			replacements: map[string]string{
+				"A": "__B__",
+				"B": "b",
+			},
+			expected: "__B__ b",
+		},
+		{
+			name:    "longest known name wins",
+			content: "__API__ __API_KEY__",
+			replacements: map[string]string{
+				"API":     "api",
+				"API_KEY": "key",
+			},
+			expected: "api key",
+		},
+		{
+			name:    "name containing the delimiter",
+			content: "__A__B__ __A__",
+			replacements: map[string]string{
+				"A":    "a",
+				"A__B": "ab",
+			},
+			expected: "ab a",
+		},
+		{
+			name:         "overlapping delimiters",
+			content:      "___KEY__ and __x__KEY__",
+			replacements: map[string]string{"KEY": "v"},
+			expected:     "_v and __xv",
+		},
+		{
+			name:         "empty value replaces placeholder",
+			content:      "[__KEY__]",
+			replacements: map[string]string{"KEY": ""},
+			expected:     "[]",
+		},
+		{
+			name:         "known prefix of unknown name with default",
+			content:      "__AB:-fallback__",
+			replacements: map[string]string{"A": "a"},
+			expected:     "fallback",
+		},
+		{
+			name:         "default cannot span lines",
+			content:      "__KEY:-multi\nline__",
+			replacements: map[string]string{},
+			expected:     "__KEY:-multi\nline__",
+		},
+		{
+			name:         "unterminated placeholder",
+			content:      "const x = '__KEY",
+			replacements: map[string]string{"KEY": "v"},
+			expected:     "const x = '__KEY",
+		},
+		{
+			name:         "required without value",
+			content:      "__A:?first__ __B:?__ __C__",
+			replacements: map[string]string{"C": "c"},
+			expected:     "__A:?first__ __B:?__ c",
+			missing:      []string{"A (first)", "B"},
+		},
+		{
+			name:         "utf-8 values",
+			content:      "<h1>__TITLE__</h1>",
+			replacements: map[string]string{"TITLE": "Grüße ✓"},
+			expected:     "<h1>Grüße ✓</h1>",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := newEngine(tt.replacements).replace([]byte(tt.content), nil)
+
+			if string(result.content) != tt.expected {
+				t.Errorf("expected:\n%s\ngot:\n%s", tt.expected, result.content)
+			}
+
+			if strings.Join(result.missing, "|") != strings.Join(tt.missing, "|") {
+				t.Errorf("expected missing %v, got %v", tt.missing, result.missing)
+			}
+		})
+	}
+}
+
+func TestEngineEdits(t *testing.T) {
+	content := []byte("a __A__ b __B:-x__ c __C:?__")
+	result := newEngine(map[string]string{"A": "long value"}).replace(content, nil)
+
+	expected := []edit{
+		{start: 2, end: 7, value: "long value"},
+		{start: 10, end: 18, value: "x"},
+	}
+	if fmt.Sprint(result.edits) != fmt.Sprint(expected) {
+		t.Errorf("expected edits %v, got %v", expected, result.edits)
+	}
+}
+
+func TestEngineDelimiters(t *testing.T) {
+	replacements := map[string]string{"API": "https://x", "ENV": "qa"}
+
+	tests := []struct {
+		open, close string
+		content     string
+		expected    string
+	}{
+		{"${", "}", "${API} ${ENV|upper} ${PORT:-8080} __API__", "https://x QA 8080 __API__"},
+		{"{{", "}}", "{{API}}/{{ENV}} {{API }}", "https://x/qa {{API }}"},
+		{"%%", "%%", "%%API%%%%ENV%% %%MISSING%%", "https://xqa %%MISSING%%"},
+		// A closing delimiter inside a default ends it early
+		{"${", "}", "${A:-{}}", "{}"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.open+"NAME"+tt.close, func(t *testing.T) {
+			e := newEngine(replacements)
+			e.open, e.close = []byte(tt.open), []byte(tt.close)
+
+			if got := string(e.replace([]byte(tt.content), nil).content); got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestEngineDeterministic(t *testing.T) {
+	replacements := make(map[string]string)
+	for i := 0; i < 50; i++ {
+		replacements[fmt.Sprintf("KEY_%d", i)] = fmt.Sprintf("__KEY_%d__", (i+1)%50)
+	}
+
+	content := []byte(generateAsset(10, replacements))
+	first := newEngine(replacements).replace(content, nil).content
+
+	for i := 0; i < 20; i++ {
+		result := newEngine(replacements).replace(content, nil).content
+		if !bytes.Equal(result, first) {
+			t.Fatal("expected identical output across runs")
+		}
+	}
+}
+
+func TestEngineUnchangedContentNotCopied(t *testing.T) {
+	content := []byte("nothing to replace here")
+	result := newEngine(map[string]string{"KEY": "v"}).replace(content, nil).content
+
+	if &result[0] != &content[0] {
+		t.Error("expected unchanged content to be returned without copying")
+	}
+}
+
+// generateAsset builds a minified-bundle-like string of roughly kb kilobytes
+// that references every replacement
+func generateAsset(kb int, replacements map[string]string) string {
+	var names []string
+	for name := range replacements {
+		names = append(names, name)
+	}
+
+	var b strings.Builder
+	for i := 0; b.Len() < kb*1024; i++ {
+		fmt.Fprintf(&b, "function f%d(a,b){return a.__proto__===b?__webpack_require__(%d):null};", i, i)
+		if i%20 == 0 && len(names) > 0 {
+			fmt.Fprintf(&b, "var c%d='__%s__';", i, names[i%len(names)])
+		}
+	}
+	return b.String()
+}
+
+// benchmarkReplacements returns n placeholder names with URL-sized values
+func benchmarkReplacements(n int) map[string]string {
+	replacements := make(map[string]string, n)
+	for i := 0; i < n; i++ {
+		replacements[fmt.Sprintf("CONFIG_VALUE_%d", i)] = fmt.Sprintf("https://service-%d.example.com/api", i)
+	}
+	return replacements
+}
+
+// replaceAllBaseline is the previous implementation, one strings.ReplaceAll
+// pass per replacement, kept for comparison
+func replaceAllBaseline(content []byte, replacements map[string]string) []byte {
+	contentStr := string(content)
+	for placeholder, value := range replacements {
+		contentStr = strings.ReplaceAll(contentStr, "__"+placeholder+"__", value)
+	}
+	return []byte(contentStr)
+}
+
+func BenchmarkTransform(b *testing.B) {
+	for _, size := range []int{64, 1024} {
+		for _, count := range []int{10, 100} {
+			replacements := benchmarkReplacements(count)
+			content := []byte(generateAsset(size, replacements))
+
+			b.Run(fmt.Sprintf("engine/%dKB/%dvars", size, count), func(b *testing.B) {
+				e := newEngine(replacements)
+				b.SetBytes(int64(len(content)))
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					e.replace(content, nil)
+				}
+			})
+
+			b.Run(fmt.Sprintf("replaceAll/%dKB/%dvars", size, count), func(b *testing.B) {
+				b.SetBytes(int64(len(content)))
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					replaceAllBaseline(content, replacements)
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkTransformAll(b *testing.B) {
+	// 200 files of 256KB, roughly the size of a large SPA build
+	const files, sizeKB = 200, 256
+
+	replacements := benchmarkReplacements(50)
+	content := generateAsset(sizeKB, replacements)
+
+	dir := b.TempDir()
+	for i := 0; i < files; i++ {
+		path := filepath.Join(dir, fmt.Sprintf("chunk-%d", i/50), fmt.Sprintf("part-%d.js", i))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			b.Fatalf("failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			b.Fatalf("failed to create file: %v", err)
+		}
+	}
+
+	b.SetBytes(int64(files * len(content)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		trans := New(dir, replacements)
+		if err := trans.TransformAll(); err != nil {
+			b.Fatalf("TransformAll failed: %v", err)
+		}
+	}
+}
@@ -0,0 +1,499 @@
+package transformer
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// valueContext is the syntactic context a placeholder appears in, which
+// decides how its value is escaped
+type valueContext int
+
+const (
+	ctxRaw          valueContext = iota // inserted verbatim
+	ctxJSONString                       // inside a JSON string literal
+	ctxJSString                         // inside a '...' or "..." JavaScript string
+	ctxJSTemplate                       // inside a `...` JavaScript template literal
+	ctxHTMLText                         // HTML element content
+	ctxHTMLAttr                         // quoted HTML attribute value
+	ctxHTMLUnquoted                     // unquoted HTML attribute value
+	ctxURLQuery                         // query or fragment of a URL attribute value
+	ctxJSCode                           // JavaScript code outside strings and comments
+	ctxJSONValue                        // JSON outside string literals
+)
+
+// WithEscaping enables or disables context-aware escaping of replacement
+// values. Escaping is enabled by default.
+func WithEscaping(enabled bool) Option {
+	return func(t *Transformer) {
+		t.escape = enabled
+	}
+}
+
+// WithRawPlaceholders inserts the given placeholders verbatim, even when
+// escaping is enabled, e.g. for values that deliberately contain markup
+func WithRawPlaceholders(names ...string) Option {
+	return func(t *Transformer) {
+		for _, name := range names {
+			t.raw[name] = true
+		}
+	}
+}
+
+// contextTracker follows the syntax of a file so the engine knows the
+// context at each placeholder. Trackers only move forward, keeping the
+// transformation a single pass.
+type contextTracker interface {
+	// advance lexes the original content up to offset to
+	advance(content []byte, to int)
+	// skip moves past a placeholder token without lexing it
+	skip(to int)
+	// context returns the context at the current position
+	context() valueContext
+}
+
+// newContextTracker returns a tracker for the file type of relPath, or nil
+// if values in this kind of file are always inserted verbatim
+func newContextTracker(relPath string) contextTracker {
+	switch strings.ToLower(filepath.Ext(relPath)) {
+	case ".json", ".map", ".webmanifest":
+		return &jsonTracker{}
+	case ".js", ".mjs", ".cjs", ".jsx", ".ts", ".tsx":
+		return &jsTracker{}
+	case ".html", ".htm", ".xml", ".svg":
+		return &htmlTracker{}
+	default:
+		return nil
+	}
+}
+
+// escapeValue escapes value for the context it is inserted into
+func escapeValue(ctx valueContext, value string) string {
+	switch ctx {
+	case ctxJSONString:
+		encoded, _ := json.Marshal(value)
+		return string(encoded[1 : len(encoded)-1])
+	case ctxJSString:
+		return escapeJSString(value, false)
+	case ctxJSTemplate:
+		return escapeJSString(value, true)
+	case ctxHTMLText, ctxHTMLAttr:
+		return html.EscapeString(value)
+	case ctxHTMLUnquoted:
+		return escapeUnquotedAttr(value)
+	case ctxURLQuery:
+		return url.QueryEscape(value)
+	default:
+		return value
+	}
+}
+
+// escapeUnquotedAttr escapes a value for an unquoted attribute, where
+// spaces, quotes, "=", "`" and ">" would end the value or start another
+// attribute. Everything but letters, digits, "_", "." and "-" becomes a
+// character reference.
+func escapeUnquotedAttr(value string) string {
+	var b strings.Builder
+	for _, r := range value {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '.', r == '-':
+			b.WriteRune(r)
+		default:
+			fmt.Fprintf(&b, "&#x%X;", r)
+		}
+	}
+	return b.String()
+}
+
+// escapeJSString escapes a value for any JavaScript string literal. Both
+// quote characters are escaped so the result is safe whichever quote the
+// literal uses, and "<" is escaped so a value cannot close an inline <script>.
+func escapeJSString(value string, template bool) string {
+	var b strings.Builder
+	for _, r := range value {
+		switch r {
+		case '\\':
+			b.WriteString(`\\`)
+		case '\'':
+			b.WriteString(`\'`)
+		case '"':
+			b.WriteString(`\"`)
+		case '`':
+			b.WriteString("\\`")
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '<':
+			b.WriteString(`\x3C`)
+		case '\u2028', '\u2029':
+			fmt.Fprintf(&b, `\u%04X`, r)
+		case '$':
+			if template {
+				b.WriteString(`\$`)
+			} else {
+				b.WriteRune(r)
+			}
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(&b, `\x%02X`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	return b.String()
+}
+
+// jsonTracker tracks whether the position is inside a JSON string
+type jsonTracker struct {
+	pos      int
+	inString bool
+	escaped  bool
+}
+
+func (t *jsonTracker) advance(content []byte, to int) {
+	for ; t.pos < to; t.pos++ {
+		c := content[t.pos]
+		switch {
+		case t.escaped:
+			t.escaped = false
+		case c == '\\' && t.inString:
+			t.escaped = true
+		case c == '"':
+			t.inString = !t.inString
+		}
+	}
+}
+
+func (t *jsonTracker) skip(to int) {
+	t.pos = max(t.pos, to)
+	t.escaped = false
+}
+
+func (t *jsonTracker) context() valueContext {
+	if t.inString {
+		return ctxJSONString
+	}
+	return ctxJSONValue
+}
+
+// jsState is a lexical state of the JavaScript tracker
+type jsState int
+
+const (
+	jsCode jsState = iota
+	jsSingleQuote
+	jsDoubleQuote
+	jsTemplate
+	jsLineComment
+	jsBlockComment
+)
+
+// jsTracker is a minimal JavaScript lexer that knows whether the position is
+// inside a string, template literal or comment. Regular expression literals
+// are not recognised; a quote inside one may confuse the tracker until the
+// end of the line.
+type jsTracker struct {
+	pos     int
+	state   jsState
+	escaped bool
+	// braces counts open braces inside each ${...} template substitution
+	braces []int
+}
+
+func (t *jsTracker) advance(content []byte, to int) {
+	for ; t.pos < to; t.pos++ {
+		t.step(content, t.pos)
+	}
+}
+
+// step lexes the byte at i
+func (t *jsTracker) step(content []byte, i int) {
+	c := content[i]
+	next := byte(0)
+	if i+1 < len(content) {
+		next = content[i+1]
+	}
+
+	if t.escaped {
+		t.escaped = false
+		return
+	}
+
+	switch t.state {
+	case jsCode:
+		switch {
+		case c == '\'':
+			t.state = jsSingleQuote
+		case c == '"':
+			t.state = jsDoubleQuote
+		case c == '`':
+			t.state = jsTemplate
+		case c == '/' && next == '/':
+			t.state = jsLineComment
+		case c == '/' && next == '*':
+			t.state = jsBlockComment
+			t.escaped = true // don't let the '*' close the comment
+		case c == '{' && len(t.braces) > 0:
+			t.braces[len(t.braces)-1]++
+		case c == '}' && len(t.braces) > 0:
+			if t.braces[len(t.braces)-1] == 0 {
+				t.braces = t.braces[:len(t.braces)-1]
+				t.state = jsTemplate
+			} else {
+				t.braces[len(t.braces)-1]--
+			}
+		}
+	case jsSingleQuote, jsDoubleQuote:
+		quote := byte('\'')
+		if t.state == jsDoubleQuote {
+			quote = '"'
+		}
+		switch c {
+		case '\\':
+			t.escaped = true
+		case quote, '\n':
+			t.state = jsCode
+		}
+	case jsTemplate:
+		switch {
+		case c == '\\':
+			t.escaped = true
+		case c == '`':
+			t.state = jsCode
+		case c == '$' && next == '{':
+			t.braces = append(t.braces, 0)
+			t.state = jsCode
+			t.escaped = true // skip the '{'
+		}
+	case jsLineComment:
+		if c == '\n' {
+			t.state = jsCode
+		}
+	case jsBlockComment:
+		if c == '*' && next == '/' {
+			t.state = jsCode
+			t.escaped = true // skip the '/'
+		}
+	}
+}
+
+func (t *jsTracker) skip(to int) {
+	t.pos = max(t.pos, to)
+	t.escaped = false
+}
+
+func (t *jsTracker) context() valueContext {
+	switch t.state {
+	case jsSingleQuote, jsDoubleQuote:
+		return ctxJSString
+	case jsTemplate:
+		return ctxJSTemplate
+	case jsCode:
+		return ctxJSCode
+	default:
+		return ctxRaw
+	}
+}
+
+// htmlState is a lexical state of the HTML tracker
+type htmlState int
+
+const (
+	htmlText htmlState = iota
+	htmlTag
+	htmlAttrValue
+	htmlComment
+	htmlScript
+	htmlStyle
+)
+
+// urlAttributes hold URLs; values after "?" or "#" are query-escaped
+var urlAttributes = map[string]bool{
+	"href": true, "src": true, "action": true, "formaction": true,
+	"poster": true, "cite": true, "data": true, "background": true,
+}
+
+// htmlTracker is a minimal HTML lexer that knows whether the position is in
+// element text, an attribute value, or an inline script (delegated to a
+// JavaScript or, for JSON script types, a JSON tracker). Style elements and
+// comments are treated as raw.
+type htmlTracker struct {
+	pos        int
+	state      htmlState
+	tagName    []byte
+	inName     bool   // reading the tag name
+	attr       []byte // name of the current or last attribute
+	inAttr     bool   // reading an attribute name
+	value      []byte // value of the current attribute
+	quote      byte   // quote of the current attribute value, 0 if unquoted
+	inQuery    bool   // a "?" or "#" was seen in a URL attribute value
+	scriptType string // type attribute of the current tag
+	script     contextTracker
+}
+
+func (t *htmlTracker) advance(content []byte, to int) {
+	for ; t.pos < to; t.pos++ {
+		c := content[t.pos]
+		rest := content[t.pos:]
+
+		switch t.state {
+		case htmlText:
+			switch {
+			case hasPrefixFold(rest, "<!--"):
+				t.state = htmlComment
+				t.pos += 3
+			case c == '<' && len(rest) > 1 && (isLetter(rest[1]) || rest[1] == '/'):
+				t.state = htmlTag
+				t.tagName = t.tagName[:0]
+				t.inName = true
+				t.attr = t.attr[:0]
+				t.inAttr = false
+				t.scriptType = ""
+			}
+		case htmlTag:
+			t.lexTag(c, rest)
+		case htmlAttrValue:
+			t.lexAttrValue(c)
+		case htmlComment:
+			if hasPrefixFold(rest, "-->") {
+				t.state = htmlText
+				t.pos += 2
+			}
+		case htmlScript:
+			if hasPrefixFold(rest, "</script") {
+				t.state = htmlTag
+				t.tagName = append(t.tagName[:0], "/script"...)
+				t.inName = false
+				t.pos += len("</script") - 1
+				continue
+			}
+			t.script.advance(content, t.pos+1)
+		case htmlStyle:
+			if hasPrefixFold(rest, "</style") {
+				t.state = htmlTag
+				t.tagName = append(t.tagName[:0], "/style"...)
+				t.inName = false
+				t.pos += len("</style") - 1
+			}
+		}
+	}
+}
+
+// lexTag handles a byte inside a start or end tag
+func (t *htmlTracker) lexTag(c byte, rest []byte) {
+	switch {
+	case c == '>':
+		name := strings.ToLower(string(t.tagName))
+		switch name {
+		case "script":
+			t.state = htmlScript
+			if strings.Contains(t.scriptType, "json") {
+				t.script = &jsonTracker{pos: t.pos + 1}
+			} else {
+				t.script = &jsTracker{pos: t.pos + 1}
+			}
+		case "style":
+			t.state = htmlStyle
+		default:
+			t.state = htmlText
+		}
+	case t.inName:
+		if isSpace(c) || (c == '/' && len(t.tagName) > 0) {
+			t.inName = false
+		} else {
+			t.tagName = append(t.tagName, c)
+		}
+	case c == '=':
+		t.inAttr = false
+		t.state = htmlAttrValue
+		t.quote = 0
+		t.inQuery = false
+		t.value = t.value[:0]
+		if len(rest) > 1 && (rest[1] == '"' || rest[1] == '\'') {
+			t.quote = rest[1]
+			t.pos++
+		}
+	case isSpace(c) || c == '/':
+		t.inAttr = false
+	default:
+		if !t.inAttr {
+			t.attr = t.attr[:0]
+			t.inAttr = true
+		}
+		t.attr = append(t.attr, c)
+	}
+}
+
+// lexAttrValue handles a byte inside an attribute value
+func (t *htmlTracker) lexAttrValue(c byte) {
+	switch {
+	case t.quote != 0 && c == t.quote:
+		t.endAttrValue()
+	case t.quote == 0 && (isSpace(c) || c == '>'):
+		t.endAttrValue()
+		if c == '>' {
+			t.lexTag(c, nil)
+		}
+	default:
+		if c == '?' || c == '#' {
+			t.inQuery = true
+		}
+		t.value = append(t.value, c)
+	}
+}
+
+// endAttrValue returns to the tag after an attribute value, remembering the
+// type attribute to tell JSON scripts apart from JavaScript
+func (t *htmlTracker) endAttrValue() {
+	t.state = htmlTag
+	if strings.EqualFold(string(t.attr), "type") {
+		t.scriptType = strings.ToLower(string(t.value))
+	}
+}
+
+func (t *htmlTracker) skip(to int) {
+	t.pos = max(t.pos, to)
+	if t.script != nil {
+		t.script.skip(to)
+	}
+}
+
+func (t *htmlTracker) context() valueContext {
+	switch t.state {
+	case htmlText:
+		return ctxHTMLText
+	case htmlAttrValue:
+		if t.inQuery && urlAttributes[strings.ToLower(string(t.attr))] {
+			return ctxURLQuery
+		}
+		if t.quote == 0 {
+			return ctxHTMLUnquoted
+		}
+		return ctxHTMLAttr
+	case htmlScript:
+		return t.script.context()
+	default:
+		return ctxRaw
+	}
+}
+
+// hasPrefixFold reports whether b starts with the ASCII prefix, ignoring case
+func hasPrefixFold(b []byte, prefix string) bool {
+	return len(b) >= len(prefix) && strings.EqualFold(string(b[:len(prefix)]), prefix)
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
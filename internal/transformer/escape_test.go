@@ -0,0 +1,202 @@
+package transformer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEscapeValue(t *testing.T) {
+	tests := []struct {
+		name     string
+		ctx      valueContext
+		value    string
+		expected string
+	}{
+		{"raw", ctxRaw, `<b>"x"</b>`, `<b>"x"</b>`},
+		{"json string", ctxJSONString, "a \"quoted\"\nvalue\\", `a \"quoted\"\nvalue\\`},
+		{"js string", ctxJSString, `it's "x" </script>`, `it\'s \"x\" \x3C/script>`},
+		{"js string line separator", ctxJSString, "a\u2028b", `a\u2028b`},
+		{"js string keeps dollar", ctxJSString, "${x}", "${x}"},
+		{"js template", ctxJSTemplate, "`${x}`", "\\`\\${x}\\`"},
+		{"html text", ctxHTMLText, `<script>&`, `&lt;script&gt;&amp;`},
+		{"html attribute", ctxHTMLAttr, `" onload="x`, `&#34; onload=&#34;x`},
+		{"unquoted attribute", ctxHTMLUnquoted, "x onmouseover=alert(1)`>", "x&#x20;onmouseover&#x3D;alert&#x28;1&#x29;&#x60;&#x3E;"},
+		{"url query", ctxURLQuery, "a b&c=d", "a+b%26c%3Dd"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := escapeValue(tt.ctx, tt.value); got != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestTransformEscaping(t *testing.T) {
+	replacements := map[string]string{
+		"TITLE": `Tom & "Jerry"`,
+		"NEXT":  "/a b?c=d",
+		"CODE":  "window.x = 1",
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		content  string
+		expected string
+	}{
+		{
+			name:     "json string",
+			path:     "config.json",
+			content:  `{"title": "__TITLE__"}`,
+			expected: `{"title": "Tom \u0026 \"Jerry\""}`,
+		},
+		{
+			name:     "json outside string",
+			path:     "config.json",
+			content:  `{"title": __TITLE__}`,
+			expected: `{"title": Tom & "Jerry"}`,
+		},
+		{
+			name:     "js single quoted string",
+			path:     "main.js",
+			content:  `const t = '__TITLE__';`,
+			expected: `const t = 'Tom & \"Jerry\"';`,
+		},
+		{
+			name:     "js template literal",
+			path:     "main.js",
+			content:  "const t = `${a}__TITLE__`;",
+			expected: "const t = `${a}Tom & \\\"Jerry\\\"`;",
+		},
+		{
+			name:     "js code after template substitution",
+			path:     "main.js",
+			content:  "const t = `${ {a: 1}.a }`; __CODE__",
+			expected: "const t = `${ {a: 1}.a }`; window.x = 1",
+		},
+		{
+			name:     "js escaped quote keeps string",
+			path:     "main.js",
+			content:  `const t = "a\"__TITLE__";`,
+			expected: `const t = "a\"Tom & \"Jerry\"";`,
+		},
+		{
+			name:     "js comment",
+			path:     "main.js",
+			content:  "// it's __CODE__\n__CODE__",
+			expected: "// it's window.x = 1\nwindow.x = 1",
+		},
+		{
+			name:     "html text and attribute",
+			path:     "index.html",
+			content:  `<title>__TITLE__</title><meta content="__TITLE__">`,
+			expected: `<title>Tom &amp; &#34;Jerry&#34;</title><meta content="Tom &amp; &#34;Jerry&#34;">`,
+		},
+		{
+			name:     "html url query",
+			path:     "index.html",
+			content:  `<a href="/login?next=__NEXT__">`,
+			expected: `<a href="/login?next=%2Fa+b%3Fc%3Dd">`,
+		},
+		{
+			name:     "html inline script",
+			path:     "index.html",
+			content:  `<script>var t = "__TITLE__"; __CODE__</script><p>__TITLE__</p>`,
+			expected: `<script>var t = "Tom & \"Jerry\""; window.x = 1</script><p>Tom &amp; &#34;Jerry&#34;</p>`,
+		},
+		{
+			name:     "html json script",
+			path:     "index.html",
+			content:  `<script type="application/json">{"t": "__TITLE__"}</script>`,
+			expected: `<script type="application/json">{"t": "Tom \u0026 \"Jerry\""}</script>`,
+		},
+		{
+			name:     "html comment",
+			path:     "index.html",
+			content:  `<!-- __TITLE__ -->`,
+			expected: `<!-- Tom & "Jerry" -->`,
+		},
+		{
+			name:     "default is not escaped",
+			path:     "index.html",
+			content:  `<p>__UNSET:-<b>none</b>__</p>`,
+			expected: `<p><b>none</b></p>`,
+		},
+		{
+			name:     "other file types are raw",
+			path:     "styles.css",
+			content:  `content: "__TITLE__";`,
+			expected: `content: "Tom & "Jerry"";`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trans := New("", replacements)
+			result, err := trans.transform(tt.path, []byte(tt.content))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(result) != tt.expected {
+				t.Errorf("expected:\n%s\ngot:\n%s", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestTransformEscapingOptOut(t *testing.T) {
+	replacements := map[string]string{
+		"BANNER": "<b>Beta</b>",
+		"TITLE":  "<i>App</i>",
+	}
+	content := `<div>__BANNER__</div><h1>__TITLE__</h1>`
+
+	trans := New("", replacements, WithRawPlaceholders("BANNER"))
+	result, _ := trans.transform("index.html", []byte(content))
+	expected := `<div><b>Beta</b></div><h1>&lt;i&gt;App&lt;/i&gt;</h1>`
+	if string(result) != expected {
+		t.Errorf("raw placeholder: expected %s, got %s", expected, result)
+	}
+
+	trans = New("", replacements, WithEscaping(false))
+	result, _ = trans.transform("index.html", []byte(content))
+	expected = `<div><b>Beta</b></div><h1><i>App</i></h1>`
+	if string(result) != expected {
+		t.Errorf("escaping disabled: expected %s, got %s", expected, result)
+	}
+}
+
+func TestTransformAllEscapesByFileType(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"config.json": `{"name": "__NAME__"}`,
+		"app.js":      `const name = "__NAME__";`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to create file: %v", err)
+		}
+	}
+
+	trans := New(tmpDir, map[string]string{"NAME": `say "hi"`})
+	if err := trans.TransformAll(); err != nil {
+		t.Fatalf("TransformAll failed: %v", err)
+	}
+
+	expected := map[string]string{
+		"config.json": `{"name": "say \"hi\""}`,
+		"app.js":      `const name = "say \"hi\"";`,
+	}
+	for name, want := range expected {
+		got, ok := trans.GetCache().Get(name)
+		if !ok {
+			t.Fatalf("%s not cached", name)
+		}
+		if string(got) != want {
+			t.Errorf("%s: expected %s, got %s", name, want, got)
+		}
+	}
+}
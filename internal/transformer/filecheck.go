@@ -0,0 +1,81 @@
+package transformer
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// FileIssue is a file handled in a way its content suggests is wrong
+type FileIssue struct {
+	File    string `json:"file"`
+	Line    int    `json:"line,omitempty"`
+	Message string `json:"message"`
+}
+
+// CheckFiles walks the asset directory for text files that contain
+// placeholders but are not transformed, and for binary files that are
+// selected for transformation. Files are reported in walk order.
+func (t *Transformer) CheckFiles() ([]FileIssue, error) {
+	var issues []FileIssue
+
+	err := filepath.WalkDir(t.assetDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		relPath, err := t.relativePath(path)
+		if err != nil {
+			return err
+		}
+
+		info, err := os.Stat(path)
+		if err != nil || !info.Mode().IsRegular() || info.Size() == 0 {
+			return nil
+		}
+
+		selected := t.selectFile(path, relPath)
+		text := looksLikeText(path)
+
+		if selected && !text {
+			issues = append(issues, FileIssue{
+				File:    relPath,
+				Message: "binary file is selected for transformation, exclude it with TRANSFORM_EXCLUDE",
+			})
+			return nil
+		}
+
+		if selected || !text {
+			return nil
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		found := findUnresolved(t.audit, relPath, content, t.ignored)
+		if len(found) == 0 {
+			return nil
+		}
+
+		reason := "its file type is not transformed, include it with TRANSFORM_INCLUDE"
+		if matchAny(t.rules.Exclude, relPath) {
+			reason = "it is excluded by TRANSFORM_EXCLUDE"
+		}
+		issues = append(issues, FileIssue{
+			File:    relPath,
+			Line:    found[0].Line,
+			Message: fmt.Sprintf("contains %d placeholder(s) such as %s, but %s", len(found), found[0].Token, reason),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to check files: %w", err)
+	}
+
+	return issues, nil
+}
@@ -0,0 +1,59 @@
+package transformer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckFiles(t *testing.T) {
+	assetDir := t.TempDir()
+	files := map[string]string{
+		"index.html":         "<title>__TITLE__</title>",
+		"site.webmanifest":   "{\n\"name\": \"__TITLE__\"}",
+		"vendor/lib.js":      "var a = '__TITLE__';",
+		"notes.webmanifest":  "{\"name\": \"static\", \"dev\": \"__DEV__\"}",
+		"images/logo.png":    "\x89PNG\x00\x00__TITLE__",
+		"images/icon.png":    "\x89PNG\x00\x00",
+		"fonts/included.bin": "\x00\x01\x02",
+	}
+	for name, content := range files {
+		path := filepath.Join(assetDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to create file: %v", err)
+		}
+	}
+
+	trans := New(assetDir, map[string]string{"TITLE": "Demo"}, WithRules(Rules{
+		Include: []string{"*.bin"},
+		Exclude: []string{"vendor/**"},
+	}))
+
+	issues, err := trans.CheckFiles()
+	if err != nil {
+		t.Fatalf("CheckFiles failed: %v", err)
+	}
+
+	expected := map[string]string{
+		"fonts/included.bin": "binary file is selected",
+		"site.webmanifest":   "not transformed, include it with TRANSFORM_INCLUDE",
+		"vendor/lib.js":      "excluded by TRANSFORM_EXCLUDE",
+	}
+	if len(issues) != len(expected) {
+		t.Fatalf("expected %d issues, got %+v", len(expected), issues)
+	}
+	for _, issue := range issues {
+		want, ok := expected[issue.File]
+		if !ok || !strings.Contains(issue.Message, want) {
+			t.Errorf("unexpected issue %+v", issue)
+		}
+	}
+
+	if issues[1].File != "site.webmanifest" || issues[1].Line != 2 {
+		t.Errorf("expected placeholder line to be reported, got %+v", issues[1])
+	}
+}
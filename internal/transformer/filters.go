@@ -0,0 +1,92 @@
+package transformer
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"html"
+	"net/url"
+	"strings"
+)
+
+// filter transforms a resolved placeholder value
+type filter func(string) string
+
+// filters are the built-in value filters, applied left to right as in
+// __NAME|trim|base64__
+var filters = map[string]filter{
+	"urlencode":  url.QueryEscape,
+	"pathencode": url.PathEscape,
+	"base64":     func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) },
+	"base64url":  func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) },
+	"json":       jsonQuote,
+	"html":       html.EscapeString,
+	"upper":      strings.ToUpper,
+	"lower":      strings.ToLower,
+	"trim":       strings.TrimSpace,
+}
+
+// errUnknownFilter marks transform errors caused by placeholders using a
+// filter that does not exist; the file is still transformed and cached
+var errUnknownFilter = errors.New("unknown placeholder filter")
+
+// IsPlaceholderError reports whether a transform error was caused by the
+// placeholders in the files, which are still transformed and cached
+func IsPlaceholderError(err error) bool {
+	return errors.Is(err, errMissingRequired) || errors.Is(err, errUnknownFilter) ||
+		errors.Is(err, errUnsafeRequestValue)
+}
+
+// applyFilters runs value through the named filters in order
+func applyFilters(value string, names []string) string {
+	for _, name := range names {
+		value = filters[name](value)
+	}
+	return value
+}
+
+// preEncoded reports whether the last encoding filter in names already
+// made the value safe in ctx, so escaping it again would only double-encode
+// it. Later case and whitespace filters are skipped: they can change the
+// encoded data (base64|lower no longer decodes) but add no characters that
+// would need escaping, so the output stays safe.
+func preEncoded(names []string, ctx valueContext) bool {
+	inString := ctx != ctxRaw && ctx != ctxJSCode && ctx != ctxJSONValue
+	for i := len(names) - 1; i >= 0; i-- {
+		switch names[i] {
+		case "urlencode", "base64", "base64url":
+			return inString
+		case "pathencode":
+			// "&" and "=" are left as-is, which would split a query
+			return inString && ctx != ctxURLQuery
+		case "html":
+			return ctx == ctxHTMLText || ctx == ctxHTMLAttr
+		case "json":
+			// Inside a string the literal becomes string contents, as
+			// JSON.parse('__NAME|json__') expects
+			return ctx == ctxJSCode || ctx == ctxJSONValue
+		}
+	}
+	return false
+}
+
+// unknownFilter returns the first of names that is not a built-in filter
+func unknownFilter(names []string) (string, bool) {
+	for _, name := range names {
+		if _, ok := filters[name]; !ok {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// jsonQuote returns s as a JSON string literal, quotes included
+func jsonQuote(s string) string {
+	b, _ := json.Marshal(s) // strings always marshal
+	return string(b)
+}
+
+// isFilterByte reports whether b may appear in a filter name
+func isFilterByte(b byte) bool {
+	return (b >= 'a' && b <= 'z') || (b >= '0' && b <= '9')
+}
@@ -0,0 +1,204 @@
+package transformer
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFilters(t *testing.T) {
+	tests := []struct {
+		filter   string
+		value    string
+		expected string
+	}{
+		{"urlencode", "a b&c=d/é", "a+b%26c%3Dd%2F%C3%A9"},
+		{"pathencode", "a b/c", "a%20b%2Fc"},
+		{"base64", "user:pa>ss?", "dXNlcjpwYT5zcz8="},
+		{"base64url", "user:pa>ss?", "dXNlcjpwYT5zcz8"},
+		{"json", `say "hi"` + "\n", `"say \"hi\"\n"`},
+		{"html", `<a href="x">&</a>`, "&lt;a href=&#34;x&#34;&gt;&amp;&lt;/a&gt;"},
+		{"upper", "Prod", "PROD"},
+		{"lower", "Prod", "prod"},
+		{"trim", "  value \n", "value"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			if got := applyFilters(tt.value, []string{tt.filter}); got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestEngineFilters(t *testing.T) {
+	replacements := map[string]string{
+		"API":   "https://api.example.com/v1?a=1",
+		"FLAGS": `{"beta":true}`,
+		"NAME":  " Demo ",
+	}
+
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+		invalid  []string
+	}{
+		{
+			name:     "single filter",
+			content:  "u=__API|urlencode__",
+			expected: "u=https%3A%2F%2Fapi.example.com%2Fv1%3Fa%3D1",
+		},
+		{
+			name:     "same value with different filters",
+			content:  "__FLAGS__ __FLAGS|base64__",
+			expected: `{"beta":true} eyJiZXRhIjp0cnVlfQ==`,
+		},
+		{
+			name:     "filters apply left to right",
+			content:  "__NAME|trim|upper__",
+			expected: "DEMO",
+		},
+		{
+			name:     "filters apply to defaults",
+			content:  "__MISSING|upper:-local__",
+			expected: "LOCAL",
+		},
+		{
+			name:     "default may contain a pipe",
+			content:  "__MISSING:-a|b__",
+			expected: "a|b",
+		},
+		{
+			name:     "unknown filter is left in place",
+			content:  "__API|rot13__ __NAME|trim__",
+			expected: "__API|rot13__ Demo",
+			invalid:  []string{"API|rot13"},
+		},
+		{
+			name:     "malformed filters are not placeholders",
+			content:  "__API|__ __API|Upper__ __API|url encode__",
+			expected: "__API|__ __API|Upper__ __API|url encode__",
+		},
+	}
+
+	e := newEngine(replacements)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := e.replace([]byte(tt.content), nil)
+			if string(result.content) != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, result.content)
+			}
+			if strings.Join(result.invalid, ",") != strings.Join(tt.invalid, ",") {
+				t.Errorf("expected invalid %v, got %v", tt.invalid, result.invalid)
+			}
+		})
+	}
+}
+
+func TestTransformFiltersAreEscaped(t *testing.T) {
+	trans := New("/tmp", map[string]string{
+		"TITLE": "Tom & Jerry",
+		"API":   "https://api.example.com/v1?a=1",
+		"FLAGS": `{"a":"b\"c"}`,
+	}, WithEscaping(true))
+
+	tests := []struct {
+		name     string
+		path     string
+		content  string
+		expected string
+	}{
+		{
+			name:     "filtered values are still escaped",
+			path:     "index.html",
+			content:  `<p>__TITLE|upper__</p><a href="/?q=__TITLE|trim__">`,
+			expected: `<p>TOM &amp; JERRY</p><a href="/?q=Tom+%26+Jerry">`,
+		},
+		{
+			name:     "urlencode in a query is not encoded twice",
+			path:     "index.html",
+			content:  `<a href="/login?next=__API|urlencode__">`,
+			expected: `<a href="/login?next=https%3A%2F%2Fapi.example.com%2Fv1%3Fa%3D1">`,
+		},
+		{
+			name:     "pathencode in a query is encoded again",
+			path:     "index.html",
+			content:  `<a href="/?q=__TITLE|pathencode__">`,
+			expected: `<a href="/?q=Tom%2520%26%2520Jerry">`,
+		},
+		{
+			name:     "html in text is not escaped twice",
+			path:     "index.html",
+			content:  `<p>__TITLE|html|upper__</p>`,
+			expected: `<p>TOM &AMP; JERRY</p>`,
+		},
+		{
+			name:     "html in a script is escaped as JavaScript",
+			path:     "app.js",
+			content:  `var t = '__TITLE|html__';`,
+			expected: `var t = 'Tom &amp; Jerry';`,
+		},
+		{
+			name:     "base64 in a string",
+			path:     "app.js",
+			content:  `atob('__FLAGS|base64__')`,
+			expected: `atob('eyJhIjoiYlwiYyJ9')`,
+		},
+		{
+			name:     "json in code",
+			path:     "app.js",
+			content:  `const flags = __FLAGS|json__;`,
+			expected: `const flags = "{\"a\":\"b\\\"c\"}";`,
+		},
+		{
+			name:     "json in a string becomes string contents",
+			path:     "app.js",
+			content:  `JSON.parse('__TITLE|json__')`,
+			expected: `JSON.parse('\"Tom \\u0026 Jerry\"')`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := trans.transform(tt.path, []byte(tt.content))
+			if err != nil {
+				t.Fatalf("transform failed: %v", err)
+			}
+			if string(result) != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestTransformAllWithUnknownFilter(t *testing.T) {
+	tempDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tempDir, "app.js"), []byte("const u = '__API|urlencoded__';"), 0644); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+
+	trans := New(tempDir, map[string]string{"API": "https://x"})
+
+	err := trans.TransformAll()
+	if err == nil || !strings.Contains(err.Error(), "app.js") || !strings.Contains(err.Error(), "API|urlencoded") {
+		t.Fatalf("expected error naming file and filter, got %v", err)
+	}
+	if !IsPlaceholderError(err) {
+		t.Errorf("expected a placeholder error, got %v", err)
+	}
+
+	// The file is still cached, with the placeholder left for the audit
+	unresolved := trans.GetCache().Unresolved()
+	if len(unresolved) != 1 || unresolved[0].Token != "__API|urlencoded__" {
+		t.Errorf("expected API to be reported as unresolved, got %+v", unresolved)
+	}
+
+	_, err = trans.transform("app.js", []byte("__API|nope__"))
+	if !errors.Is(err, errUnknownFilter) || !IsPlaceholderError(err) {
+		t.Errorf("expected unknown filter error, got %v", err)
+	}
+}
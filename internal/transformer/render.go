@@ -0,0 +1,127 @@
+package transformer
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// RenderStats counts the files written by Render
+type RenderStats struct {
+	Transformed int // files written from the cache
+	Copied      int // files copied from the asset directory as-is
+	Bytes       int64
+}
+
+// Render writes the asset directory to outDir, with transformed files taken
+// from the cache and everything else copied as-is. TransformAll must have
+// run first. outDir must be empty or not exist, and cannot be inside the
+// asset directory.
+func (t *Transformer) Render(outDir string) (RenderStats, error) {
+	var stats RenderStats
+
+	if err := checkRenderDir(t.assetDir, outDir); err != nil {
+		return stats, err
+	}
+
+	err := filepath.WalkDir(t.assetDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relPath, err := t.relativePath(path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(outDir, filepath.FromSlash(relPath))
+
+		if d.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+
+		// Follow symlinks, skipping those that point at directories
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			t.log().Warn("Skipping non-regular file", "path", relPath)
+			return nil
+		}
+
+		if entry, ok := t.cache.GetEntry(relPath); ok {
+			if err := os.WriteFile(target, entry.Content, info.Mode().Perm()); err != nil {
+				return err
+			}
+			stats.Transformed++
+			stats.Bytes += int64(len(entry.Content))
+			return nil
+		}
+
+		n, err := copyFile(path, target, info.Mode().Perm())
+		if err != nil {
+			return err
+		}
+		stats.Copied++
+		stats.Bytes += n
+		return nil
+	})
+	if err != nil {
+		return stats, fmt.Errorf("failed to render assets: %w", err)
+	}
+
+	return stats, nil
+}
+
+// checkRenderDir rejects output directories that hold files or would be
+// walked as part of the asset directory
+func checkRenderDir(assetDir, outDir string) error {
+	absAssets, err := filepath.Abs(assetDir)
+	if err != nil {
+		return err
+	}
+	absOut, err := filepath.Abs(outDir)
+	if err != nil {
+		return err
+	}
+
+	if rel, err := filepath.Rel(absAssets, absOut); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("output directory %s is inside the asset directory %s", outDir, assetDir)
+	}
+
+	entries, err := os.ReadDir(outDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("cannot read output directory: %w", err)
+	}
+	if len(entries) > 0 {
+		return fmt.Errorf("output directory %s is not empty", outDir)
+	}
+
+	return nil
+}
+
+// copyFile copies the file at src to dst, returning the bytes written
+func copyFile(src, dst string, perm fs.FileMode) (int64, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return 0, err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := io.Copy(out, in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return n, err
+}
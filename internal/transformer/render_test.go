@@ -0,0 +1,104 @@
+package transformer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	assetDir := t.TempDir()
+	files := map[string]string{
+		"index.html":        "<title>__TITLE__</title>",
+		"static/js/app.js":  "const api = '__API__';",
+		"static/logo.png":   "\x89PNG __API__",
+		"static/empty.json": "",
+	}
+	for name, content := range files {
+		path := filepath.Join(assetDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to create file: %v", err)
+		}
+	}
+
+	// A limit small enough to evict everything; Render reloads evicted files
+	trans := New(assetDir, map[string]string{"TITLE": "Demo", "API": "https://x"}, WithCacheLimit(1))
+	if err := trans.TransformAll(); err != nil {
+		t.Fatalf("TransformAll failed: %v", err)
+	}
+
+	outDir := filepath.Join(t.TempDir(), "out")
+	stats, err := trans.Render(outDir)
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+
+	expected := map[string]string{
+		"index.html":        "<title>Demo</title>",
+		"static/js/app.js":  "const api = 'https://x';",
+		"static/logo.png":   "\x89PNG __API__",
+		"static/empty.json": "",
+	}
+	for name, want := range expected {
+		got, err := os.ReadFile(filepath.Join(outDir, filepath.FromSlash(name)))
+		if err != nil {
+			t.Errorf("expected %s to be rendered: %v", name, err)
+			continue
+		}
+		if string(got) != want {
+			t.Errorf("%s: expected %q, got %q", name, want, got)
+		}
+	}
+
+	if stats.Transformed != 3 || stats.Copied != 1 {
+		t.Errorf("expected 3 transformed and 1 copied file, got %+v", stats)
+	}
+}
+
+func TestRenderOutputDirectory(t *testing.T) {
+	assetDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(assetDir, "index.html"), []byte("x"), 0644); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+
+	notEmpty := t.TempDir()
+	if err := os.WriteFile(filepath.Join(notEmpty, "stale.html"), []byte("x"), 0644); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		outDir      string
+		expectError string
+	}{
+		{"new directory", filepath.Join(t.TempDir(), "out"), ""},
+		{"empty directory", t.TempDir(), ""},
+		{"not empty", notEmpty, "not empty"},
+		{"asset directory", assetDir, "inside the asset directory"},
+		{"inside asset directory", filepath.Join(assetDir, "dist"), "inside the asset directory"},
+	}
+
+	trans := New(assetDir, map[string]string{})
+	if err := trans.TransformAll(); err != nil {
+		t.Fatalf("TransformAll failed: %v", err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := trans.Render(tt.outDir)
+			if tt.expectError == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.expectError) {
+				t.Errorf("expected error containing %q, got %v", tt.expectError, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package transformer
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"unicode/utf8"
+)
+
+// Rules selects which files are transformed on top of the built-in
+// extension list. Exclude always wins over Include and the built-in list.
+//
+// Patterns without a slash match the file name in any directory (e.g. "*.map"),
+// patterns with a slash match the path relative to the asset directory, where
+// "**" matches any number of directories (e.g. "vendor/**").
+type Rules struct {
+	Include []string
+	Exclude []string
+
+	// Sniff transforms files that match no rule and have no known extension
+	// when their first bytes look like UTF-8 text
+	Sniff bool
+}
+
+// WithRules configures which files are transformed
+func WithRules(rules Rules) Option {
+	return func(t *Transformer) {
+		t.rules = rules
+	}
+}
+
+// sniffLength is how much of a file is inspected when sniffing for text
+const sniffLength = 512
+
+// selectFile decides whether the file at path (relPath inside the asset
+// directory) should be transformed. Source maps of transformed files are
+// selected to be corrected unless excluded.
+func (t *Transformer) selectFile(path, relPath string) bool {
+	if matchAny(t.rules.Exclude, relPath) {
+		return false
+	}
+
+	if _, ok := t.sourceMapEdits(relPath); ok {
+		return true
+	}
+
+	return t.selectText(path, relPath)
+}
+
+// selectText decides whether placeholders in a file that is not excluded
+// should be replaced
+func (t *Transformer) selectText(path, relPath string) bool {
+	if matchAny(t.rules.Include, relPath) || shouldTransform(path) {
+		return true
+	}
+
+	return t.rules.Sniff && looksLikeText(path)
+}
+
+// matchAny reports whether relPath matches any of the patterns
+func matchAny(patterns []string, relPath string) bool {
+	for _, pattern := range patterns {
+		if MatchGlob(pattern, relPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchGlob matches a slash-separated path relative to the asset directory
+// against a pattern, using the same semantics as Rules
+func MatchGlob(pattern, relPath string) bool {
+	pattern = strings.TrimPrefix(pattern, "/")
+
+	if !strings.Contains(pattern, "/") {
+		matched, _ := path.Match(pattern, path.Base(relPath))
+		return matched
+	}
+
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(relPath, "/"))
+}
+
+// matchSegments matches path segments, expanding "**" to zero or more segments
+func matchSegments(pattern, parts []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(parts); i++ {
+				if matchSegments(pattern[1:], parts[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(parts) == 0 {
+			return false
+		}
+
+		if matched, _ := path.Match(pattern[0], parts[0]); !matched {
+			return false
+		}
+
+		pattern, parts = pattern[1:], parts[1:]
+	}
+
+	return len(parts) == 0
+}
+
+// looksLikeText reports whether the start of a file is valid UTF-8 without
+// NUL bytes, which rules out images, fonts and archives
+func looksLikeText(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	buf := make([]byte, sniffLength)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return false
+	}
+	buf = buf[:n]
+
+	if len(buf) == 0 || bytes.IndexByte(buf, 0) >= 0 {
+		return false
+	}
+
+	// The read may have cut a multi-byte character in half
+	for i := 0; i < utf8.UTFMax && len(buf) > 0 && !utf8.Valid(buf); i++ {
+		buf = buf[:len(buf)-1]
+	}
+
+	return utf8.Valid(buf)
+}
@@ -0,0 +1,118 @@
+package transformer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		relPath  string
+		expected bool
+	}{
+		// Patterns without a slash match the file name anywhere
+		{"*.map", "app.js.map", true},
+		{"*.map", "assets/js/app.js.map", true},
+		{"*.map", "app.js", false},
+		{"manifest.webmanifest", "static/manifest.webmanifest", true},
+		// Patterns with a slash match the relative path
+		{"vendor/*.js", "vendor/lib.js", true},
+		{"vendor/*.js", "vendor/nested/lib.js", false},
+		{"vendor/**", "vendor/nested/lib.js", true},
+		{"vendor/**/*.js", "vendor/lib.js", true},
+		{"vendor/**/*.js", "vendor/a/b/lib.js", true},
+		{"vendor/**/*.js", "src/vendor/lib.js", false},
+		{"**/vendor/*.js", "src/vendor/lib.js", true},
+		{"/config/*.tmpl", "config/app.txt.tmpl", true},
+		{"config/*.tmpl", "other/app.txt.tmpl", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.relPath, func(t *testing.T) {
+			if result := MatchGlob(tt.pattern, tt.relPath); result != tt.expected {
+				t.Errorf("MatchGlob(%q, %q) = %v, expected %v", tt.pattern, tt.relPath, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestLooksLikeText(t *testing.T) {
+	tempDir := t.TempDir()
+
+	tests := []struct {
+		name     string
+		content  []byte
+		expected bool
+	}{
+		{"plain text", []byte("API=__API_ENDPOINT__\n"), true},
+		{"utf-8 text", []byte("héllo wörld ✓"), true},
+		{"empty file", []byte{}, false},
+		{"nul bytes", []byte{0x89, 'P', 'N', 'G', 0x00, 0x01}, false},
+		{"invalid utf-8", []byte{0xff, 0xfe, 0xfd, 'a', 'b', 'c', 'd'}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(tempDir, tt.name)
+			if err := os.WriteFile(path, tt.content, 0644); err != nil {
+				t.Fatalf("failed to create test file: %v", err)
+			}
+
+			if result := looksLikeText(path); result != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestTransformAllWithRules(t *testing.T) {
+	tempDir := t.TempDir()
+
+	files := map[string]string{
+		"app.js":               "const key = '__KEY__';",
+		"app.js.map":           `{"sources":["__KEY__"]}`,
+		"site.webmanifest":     `{"name":"__KEY__"}`,
+		"vendor/huge.js":       "const key = '__KEY__';",
+		"config/app.txt.tmpl":  "key=__KEY__",
+		"VERSION":              "__KEY__",
+		"logo.png":             "\x89PNG\x00__KEY__",
+		"vendor/keep/local.js": "const key = '__KEY__';",
+	}
+	for path, content := range files {
+		fullPath := filepath.Join(tempDir, path)
+		os.MkdirAll(filepath.Dir(fullPath), 0755)
+		if err := os.WriteFile(fullPath, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to create test file %s: %v", path, err)
+		}
+	}
+
+	trans := New(tempDir, map[string]string{"KEY": "value"}, WithRules(Rules{
+		Include: []string{"*.map", "*.webmanifest", "config/*.tmpl"},
+		Exclude: []string{"vendor/*.js"},
+		Sniff:   true,
+	}))
+
+	if err := trans.TransformAll(); err != nil {
+		t.Fatalf("TransformAll failed: %v", err)
+	}
+
+	cache := trans.GetCache()
+	expectedCached := []string{"app.js", "app.js.map", "site.webmanifest", "config/app.txt.tmpl", "VERSION", "vendor/keep/local.js"}
+	for _, path := range expectedCached {
+		if _, exists := cache.Get(path); !exists {
+			t.Errorf("expected %s to be transformed", path)
+		}
+	}
+
+	for _, path := range []string{"vendor/huge.js", "logo.png"} {
+		if _, exists := cache.Get(path); exists {
+			t.Errorf("expected %s not to be transformed", path)
+		}
+	}
+
+	if cache.Size() != len(expectedCached) {
+		t.Errorf("expected %d cached files, got %d", len(expectedCached), cache.Size())
+	}
+}
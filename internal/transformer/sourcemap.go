@@ -0,0 +1,443 @@
+package transformer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// sourceMappingURLPattern matches the trailing sourceMappingURL comment of a
+// JavaScript (//# ...) or CSS (/*# ... */) file
+var sourceMappingURLPattern = regexp.MustCompile(`(?:/\*|//)[#@][ \t]*sourceMappingURL=([^\s*]+)[ \t]*(?:\*/)?\s*$`)
+
+// sourceMapTail is how much of the end of a file is searched for the
+// sourceMappingURL comment
+const sourceMapTail = 4096
+
+// lineEdit is a replacement expressed in generated line and column terms.
+// Columns are counted in UTF-16 code units, as source maps do.
+type lineEdit struct {
+	line       int // zero-based line of the placeholder
+	start, end int // columns of the placeholder
+	newLines   int // line breaks in the inserted value
+	endCol     int // column just after the inserted value, on its last line
+}
+
+// linkSourceMap records the edits made to the file that mapPath belongs to,
+// so the map is corrected whenever it is transformed. Without edits the link
+// is dropped and the map is served as-is.
+func (t *Transformer) linkSourceMap(mapPath string, edits []lineEdit) {
+	t.sourceMapsMu.Lock()
+	defer t.sourceMapsMu.Unlock()
+
+	if len(edits) == 0 {
+		delete(t.sourceMaps, mapPath)
+		return
+	}
+	t.sourceMaps[mapPath] = edits
+}
+
+// sourceMapEdits returns the edits the source map at relPath must be
+// corrected for, and whether relPath is a linked source map at all
+func (t *Transformer) sourceMapEdits(relPath string) ([]lineEdit, bool) {
+	t.sourceMapsMu.Lock()
+	defer t.sourceMapsMu.Unlock()
+
+	edits, ok := t.sourceMaps[relPath]
+	return edits, ok
+}
+
+// refreshSourceMap re-transforms the source map at mapPath after the file it
+// belongs to changed, or drops it from the cache if it no longer needs to be
+// corrected or transformed
+func (t *Transformer) refreshSourceMap(mapPath string) {
+	path := t.absolutePath(mapPath)
+	if !t.selectFile(path, mapPath) {
+		t.cache.Remove(mapPath)
+		return
+	}
+
+	// Not transformFile, so maps referencing further maps can't loop
+	if _, err := t.cacheFile(path, mapPath); err != nil && !IsPlaceholderError(err) {
+		t.log().Warn("Failed to transform source map", "path", mapPath, "error", err)
+	}
+}
+
+// sourceMapPath returns the path of the source map referenced by the
+// sourceMappingURL comment at the end of content, relative to the asset
+// directory. Inline (data:) and external maps are ignored.
+func sourceMapPath(relPath string, content []byte) (string, bool) {
+	tail := content[max(0, len(content)-sourceMapTail):]
+	match := sourceMappingURLPattern.FindSubmatch(tail)
+	if match == nil {
+		return "", false
+	}
+
+	return assetRef(relPath, string(match[1]))
+}
+
+// assetRef resolves a URL referenced from the file at relPath to a path
+// relative to the asset directory. Inline (data:) and external URLs, and
+// paths outside the asset directory, are not resolved.
+func assetRef(relPath, ref string) (string, bool) {
+	if strings.HasPrefix(ref, "data:") || strings.HasPrefix(ref, "//") || strings.Contains(ref, "://") {
+		return "", false
+	}
+
+	ref, _, _ = strings.Cut(ref, "#")
+	ref, _, _ = strings.Cut(ref, "?")
+	ref, err := url.PathUnescape(ref)
+	if err != nil || ref == "" {
+		return "", false
+	}
+
+	var resolved string
+	if strings.HasPrefix(ref, "/") {
+		resolved = path.Clean(strings.TrimPrefix(ref, "/"))
+	} else {
+		resolved = path.Join(path.Dir(relPath), ref)
+	}
+
+	if resolved == "." || resolved == ".." || strings.HasPrefix(resolved, "../") {
+		return "", false
+	}
+
+	return resolved, true
+}
+
+// lineEdits converts edits made to content into line and column terms
+func lineEdits(content []byte, edits []edit) []lineEdit {
+	result := make([]lineEdit, 0, len(edits))
+
+	line, lineStart := 0, 0
+	colPos, col := 0, 0 // col is the UTF-16 column of offset colPos
+	for _, e := range edits {
+		for {
+			i := bytes.IndexByte(content[lineStart:e.start], '\n')
+			if i < 0 {
+				break
+			}
+			line++
+			lineStart += i + 1
+			colPos, col = lineStart, 0
+		}
+
+		col += utf16Len(content[colPos:e.start])
+		colPos = e.start
+
+		le := lineEdit{
+			line:  line,
+			start: col,
+			end:   col + utf16Len(content[e.start:e.end]),
+		}
+
+		value := []byte(e.value)
+		if i := bytes.LastIndexByte(value, '\n'); i >= 0 {
+			le.newLines = bytes.Count(value, []byte{'\n'})
+			le.endCol = utf16Len(value[i+1:])
+		} else {
+			le.endCol = le.start + utf16Len(value)
+		}
+
+		result = append(result, le)
+	}
+
+	return result
+}
+
+// utf16Len returns the length of UTF-8 text in UTF-16 code units
+func utf16Len(b []byte) int {
+	n := 0
+	for len(b) > 0 {
+		if b[0] < utf8.RuneSelf {
+			n++
+			b = b[1:]
+			continue
+		}
+		r, size := utf8.DecodeRune(b)
+		if r >= 0x10000 {
+			n += 2
+		} else {
+			n++
+		}
+		b = b[size:]
+	}
+	return n
+}
+
+// correctSourceMap rewrites the mappings of a source map to account for the
+// edits made to its generated file. Everything but the mappings string is
+// left byte for byte as it was.
+func correctSourceMap(content []byte, edits []lineEdit) ([]byte, error) {
+	if len(edits) == 0 {
+		return content, nil
+	}
+
+	start, end, mappings, err := findMappings(content)
+	if err != nil {
+		return nil, err
+	}
+
+	segments, lines, err := decodeMappings(mappings)
+	if err != nil {
+		return nil, err
+	}
+
+	shiftSegments(segments, edits)
+
+	added := 0
+	for _, e := range edits {
+		added += e.newLines
+	}
+
+	encoded, err := json.Marshal(encodeMappings(segments, lines+added))
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]byte, 0, len(content)-(end-start)+len(encoded))
+	result = append(result, content[:start]...)
+	result = append(result, encoded...)
+	return append(result, content[end:]...), nil
+}
+
+// findMappings locates the "mappings" string of a source map, returning the
+// byte range of its JSON literal and its value
+func findMappings(content []byte) (start, end int, mappings string, err error) {
+	dec := json.NewDecoder(bytes.NewReader(content))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return 0, 0, "", errors.New("source map is not a JSON object")
+	}
+
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return 0, 0, "", err
+		}
+
+		switch tok {
+		case "mappings":
+			before := int(dec.InputOffset())
+			if err := dec.Decode(&mappings); err != nil {
+				return 0, 0, "", fmt.Errorf("invalid mappings: %w", err)
+			}
+			end = int(dec.InputOffset())
+			start = before + bytes.IndexByte(content[before:end], '"')
+			return start, end, mappings, nil
+		case "sections":
+			return 0, 0, "", errors.New("index source maps are not supported")
+		default:
+			var skip json.RawMessage
+			if err := dec.Decode(&skip); err != nil {
+				return 0, 0, "", err
+			}
+		}
+	}
+
+	return 0, 0, "", errors.New("source map has no mappings")
+}
+
+// segment is a decoded mapping segment with absolute values
+type segment struct {
+	line, col int
+	fields    int // 1, 4 or 5
+	source    int
+	origLine  int
+	origCol   int
+	name      int
+}
+
+// shiftSegments moves the generated positions of segments past the edits,
+// which are ordered by position. Positions inside a replaced placeholder move
+// to the start of its value.
+func shiftSegments(segments []segment, edits []lineEdit) {
+	// added[k] counts the line breaks inserted by edits[:k]
+	added := make([]int, len(edits)+1)
+	for k, e := range edits {
+		added[k+1] = added[k] + e.newLines
+	}
+
+	for i := range segments {
+		seg := &segments[i]
+
+		first := sort.Search(len(edits), func(k int) bool { return edits[k].line >= seg.line })
+		line := seg.line + added[first]
+
+		col, delta := seg.col, 0
+		for _, e := range edits[first:] {
+			if e.line != seg.line || col <= e.start {
+				break
+			}
+			if col < e.end {
+				col = e.start
+				break
+			}
+			if e.newLines > 0 {
+				line += e.newLines
+				delta = e.endCol - e.end
+			} else {
+				delta += e.endCol - e.end
+			}
+		}
+
+		seg.line, seg.col = line, col+delta
+	}
+
+	sort.SliceStable(segments, func(i, j int) bool {
+		if segments[i].line != segments[j].line {
+			return segments[i].line < segments[j].line
+		}
+		return segments[i].col < segments[j].col
+	})
+}
+
+// base64VLQ is the alphabet of source map VLQ digits
+const base64VLQ = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+
+// decodeMappings decodes a source map mappings string into segments with
+// absolute values, returning them with the number of generated lines
+func decodeMappings(mappings string) ([]segment, int, error) {
+	var segments []segment
+	var line, col, source, origLine, origCol, name int
+
+	for i := 0; i < len(mappings); {
+		switch mappings[i] {
+		case ';':
+			line++
+			col = 0
+			i++
+			continue
+		case ',':
+			i++
+			continue
+		}
+
+		var values [5]int
+		n := 0
+		for i < len(mappings) && mappings[i] != ',' && mappings[i] != ';' {
+			if n == len(values) {
+				return nil, 0, fmt.Errorf("segment with too many fields on line %d", line+1)
+			}
+			value, next, err := decodeVLQ(mappings, i)
+			if err != nil {
+				return nil, 0, err
+			}
+			values[n] = value
+			n++
+			i = next
+		}
+		if n != 1 && n != 4 && n != 5 {
+			return nil, 0, fmt.Errorf("segment with %d fields on line %d", n, line+1)
+		}
+
+		col += values[0]
+		seg := segment{line: line, col: col, fields: n}
+		if n >= 4 {
+			source += values[1]
+			origLine += values[2]
+			origCol += values[3]
+			seg.source, seg.origLine, seg.origCol = source, origLine, origCol
+		}
+		if n == 5 {
+			name += values[4]
+			seg.name = name
+		}
+		segments = append(segments, seg)
+	}
+
+	return segments, line + 1, nil
+}
+
+// encodeMappings encodes segments ordered by position into a mappings
+// string spanning the given number of generated lines
+func encodeMappings(segments []segment, lines int) string {
+	var b strings.Builder
+	var line, col, source, origLine, origCol, name int
+	first := true
+
+	for _, seg := range segments {
+		for line < seg.line {
+			b.WriteByte(';')
+			line++
+			col = 0
+			first = true
+		}
+		if !first {
+			b.WriteByte(',')
+		}
+		first = false
+
+		encodeVLQ(&b, seg.col-col)
+		col = seg.col
+		if seg.fields >= 4 {
+			encodeVLQ(&b, seg.source-source)
+			encodeVLQ(&b, seg.origLine-origLine)
+			encodeVLQ(&b, seg.origCol-origCol)
+			source, origLine, origCol = seg.source, seg.origLine, seg.origCol
+		}
+		if seg.fields == 5 {
+			encodeVLQ(&b, seg.name-name)
+			name = seg.name
+		}
+	}
+
+	for ; line < lines-1; line++ {
+		b.WriteByte(';')
+	}
+
+	return b.String()
+}
+
+// decodeVLQ decodes the base64 VLQ value starting at i, returning it with
+// the offset of the next value
+func decodeVLQ(s string, i int) (int, int, error) {
+	value, shift := 0, 0
+	for {
+		if i >= len(s) {
+			return 0, 0, errors.New("truncated VLQ value in mappings")
+		}
+		digit := strings.IndexByte(base64VLQ, s[i])
+		if digit < 0 {
+			return 0, 0, fmt.Errorf("invalid character %q in mappings", s[i])
+		}
+		i++
+
+		value += (digit & 31) << shift
+		shift += 5
+		if digit&32 == 0 {
+			break
+		}
+	}
+
+	if value&1 == 1 {
+		return -(value >> 1), i, nil
+	}
+	return value >> 1, i, nil
+}
+
+// encodeVLQ appends value as base64 VLQ digits
+func encodeVLQ(b *strings.Builder, value int) {
+	v := value << 1
+	if value < 0 {
+		v = (-value << 1) | 1
+	}
+
+	for {
+		digit := v & 31
+		v >>= 5
+		if v > 0 {
+			digit |= 32
+		}
+		b.WriteByte(base64VLQ[digit])
+		if v == 0 {
+			return
+		}
+	}
+}
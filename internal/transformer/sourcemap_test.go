@@ -0,0 +1,220 @@
+package transformer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMappingsRoundTrip(t *testing.T) {
+	tests := []string{
+		"AAAA,IAAI,YAAY",
+		"AAAA;;ACAA,SAASA;AAAgBC",
+		"A,C;E",
+		";;AAAA",
+	}
+
+	for _, mappings := range tests {
+		t.Run(mappings, func(t *testing.T) {
+			segments, lines, err := decodeMappings(mappings)
+			if err != nil {
+				t.Fatalf("decode failed: %v", err)
+			}
+			if got := encodeMappings(segments, lines); got != mappings {
+				t.Errorf("expected %s, got %s", mappings, got)
+			}
+		})
+	}
+}
+
+func TestDecodeMappingsInvalid(t *testing.T) {
+	for _, mappings := range []string{"AA", "AAAAAAA", "A!AA", "AAAg"} {
+		if _, _, err := decodeMappings(mappings); err == nil {
+			t.Errorf("expected error for %q", mappings)
+		}
+	}
+}
+
+func TestSourceMapPath(t *testing.T) {
+	tests := []struct {
+		name     string
+		relPath  string
+		content  string
+		expected string
+	}{
+		{"js comment", "static/js/main.js", "a();\n//# sourceMappingURL=main.js.map\n", "static/js/main.js.map"},
+		{"css comment", "app.css", "a{}\n/*# sourceMappingURL=app.css.map */", "app.css.map"},
+		{"legacy marker", "app.js", "a();\n//@ sourceMappingURL=app.js.map", "app.js.map"},
+		{"parent directory", "js/app.js", "//# sourceMappingURL=../maps/app.js.map", "maps/app.js.map"},
+		{"root relative", "js/app.js", "//# sourceMappingURL=/maps/app.js.map?v=1", "maps/app.js.map"},
+		{"escaped", "app.js", "//# sourceMappingURL=my%20app.js.map", "my app.js.map"},
+		{"inline map", "app.js", "//# sourceMappingURL=data:application/json;base64,e30=", ""},
+		{"external map", "app.js", "//# sourceMappingURL=https://cdn.example.com/app.js.map", ""},
+		{"outside asset directory", "app.js", "//# sourceMappingURL=../app.js.map", ""},
+		{"not at the end", "app.js", "//# sourceMappingURL=app.js.map\nmore();", ""},
+		{"no comment", "app.js", "a();", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := sourceMapPath(tt.relPath, []byte(tt.content))
+			if ok != (tt.expected != "") || got != tt.expected {
+				t.Errorf("expected %q, got %q (ok=%v)", tt.expected, got, ok)
+			}
+		})
+	}
+}
+
+func TestLineEdits(t *testing.T) {
+	content := []byte("ab __A__ c\n😀 __B__ __C__")
+	edits := []edit{
+		{start: 3, end: 8, value: "xy"},
+		{start: 16, end: 21, value: "1\n22\n333"},
+		{start: 22, end: 27, value: "é"},
+	}
+
+	expected := []lineEdit{
+		{line: 0, start: 3, end: 8, endCol: 5},
+		// The emoji counts as two UTF-16 code units
+		{line: 1, start: 3, end: 8, newLines: 2, endCol: 3},
+		{line: 1, start: 9, end: 14, endCol: 10},
+	}
+
+	got := lineEdits(content, edits)
+	if fmt.Sprint(got) != fmt.Sprint(expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestShiftSegments(t *testing.T) {
+	edits := []lineEdit{
+		{line: 0, start: 2, end: 9, endCol: 4},                // shorter value
+		{line: 0, start: 12, end: 19, newLines: 1, endCol: 3}, // value spanning two lines
+		{line: 1, start: 0, end: 7, endCol: 10},               // longer value
+	}
+
+	segments := []segment{
+		{line: 0, col: 0},
+		{line: 0, col: 5}, // inside the first placeholder
+		{line: 0, col: 10},
+		{line: 0, col: 20},
+		{line: 1, col: 8},
+		{line: 2, col: 1},
+	}
+
+	shiftSegments(segments, edits)
+
+	expected := [][2]int{{0, 0}, {0, 2}, {0, 5}, {1, 4}, {2, 11}, {3, 1}}
+	for i, seg := range segments {
+		if seg.line != expected[i][0] || seg.col != expected[i][1] {
+			t.Errorf("segment %d: expected %v, got line %d col %d", i, expected[i], seg.line, seg.col)
+		}
+	}
+}
+
+func TestCorrectSourceMap(t *testing.T) {
+	// Generated file: var a="__API__",b=1;
+	// Segments at "var" (0), "a" (4) and "b" (16)
+	sourceMap := `{"version":3,"sources":["app.ts"],"names":[],"mappings":"AAAA,IAAI,YAAY","file":"app.js"}`
+	edits := lineEdits([]byte(`var a="__API__",b=1;`), []edit{{start: 7, end: 14, value: "https://x"}})
+
+	corrected, err := correctSourceMap([]byte(sourceMap), edits)
+	if err != nil {
+		t.Fatalf("correctSourceMap failed: %v", err)
+	}
+
+	expected := `{"version":3,"sources":["app.ts"],"names":[],"mappings":"AAAA,IAAI,cAAY","file":"app.js"}`
+	if string(corrected) != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, corrected)
+	}
+}
+
+func TestCorrectSourceMapErrors(t *testing.T) {
+	edits := []lineEdit{{line: 0, start: 0, end: 7, endCol: 1}}
+
+	for _, content := range []string{
+		`[]`,
+		`{"version":3}`,
+		`{"version":3,"sections":[]}`,
+		`{"version":3,"mappings":"!"}`,
+	} {
+		if _, err := correctSourceMap([]byte(content), edits); err == nil {
+			t.Errorf("expected error for %s", content)
+		}
+	}
+
+	// Without edits the map is returned untouched, even if invalid
+	if got, err := correctSourceMap([]byte(`[]`), nil); err != nil || string(got) != `[]` {
+		t.Errorf("expected map without edits to be untouched, got %s (%v)", got, err)
+	}
+}
+
+func TestTransformAllCorrectsSourceMaps(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"app.js":       "var a=\"__API__\",b=1;\n//# sourceMappingURL=app.js.map\n",
+		"app.js.map":   `{"version":3,"sources":["app.ts"],"names":[],"mappings":"AAAA,IAAI,YAAY"}`,
+		"plain.js":     "var b=1;\n//# sourceMappingURL=plain.js.map\n",
+		"plain.js.map": `{"version":3,"sources":["plain.ts"],"names":[],"mappings":"AAAA"}`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to create file: %v", err)
+		}
+	}
+
+	trans := New(tmpDir, map[string]string{"API": "https://x"})
+	if err := trans.TransformAll(); err != nil {
+		t.Fatalf("TransformAll failed: %v", err)
+	}
+	cache := trans.GetCache()
+
+	entry, ok := cache.GetEntry("app.js")
+	if !ok || entry.SourceMap != "app.js.map" {
+		t.Fatalf("expected app.js to reference app.js.map, got %+v", entry)
+	}
+
+	sourceMap, ok := cache.Get("app.js.map")
+	if !ok {
+		t.Fatal("expected corrected source map to be cached")
+	}
+	if !strings.Contains(string(sourceMap), `"mappings":"AAAA,IAAI,cAAY"`) {
+		t.Errorf("expected corrected mappings, got %s", sourceMap)
+	}
+
+	// Maps of files without replacements are served from disk
+	if _, ok := cache.Get("plain.js.map"); ok {
+		t.Error("expected unchanged source map not to be cached")
+	}
+
+	// Evicted maps are corrected again when reloaded
+	cache.SetMaxBytes(1)
+	cache.Get("app.js")
+	if sourceMap, ok := cache.Get("app.js.map"); !ok || !strings.Contains(string(sourceMap), "cAAY") {
+		t.Errorf("expected reloaded source map to be corrected, got %s", sourceMap)
+	}
+}
+
+func TestTransformAllExcludedSourceMap(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"app.js":     "var a=\"__API__\";\n//# sourceMappingURL=app.js.map\n",
+		"app.js.map": `{"version":3,"sources":["app.ts"],"names":[],"mappings":"AAAA"}`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to create file: %v", err)
+		}
+	}
+
+	trans := New(tmpDir, map[string]string{"API": "https://x"}, WithRules(Rules{Exclude: []string{"*.map"}}))
+	if err := trans.TransformAll(); err != nil {
+		t.Fatalf("TransformAll failed: %v", err)
+	}
+
+	if _, ok := trans.GetCache().Get("app.js.map"); ok {
+		t.Error("expected excluded source map to be left alone")
+	}
+}
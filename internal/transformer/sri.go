@@ -0,0 +1,224 @@
+package transformer
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"hash"
+	"html"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// integrityTagPattern matches the start tags of elements that can carry an
+// integrity attribute
+var integrityTagPattern = regexp.MustCompile(`(?i)<(script|link)\b[^>]*>`)
+
+// attributePattern matches an HTML attribute and its optional value
+var attributePattern = regexp.MustCompile(`([^\s"'<>/=]+)(?:\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'=<>` + "`" + `]+)))?`)
+
+// integrityHashes are the hash functions allowed in integrity metadata
+var integrityHashes = map[string]func() hash.Hash{
+	"sha256": sha256.New,
+	"sha384": sha512.New384,
+	"sha512": sha512.New,
+}
+
+// integrityAttr is an integrity attribute of a script or link tag
+type integrityAttr struct {
+	start, end int    // byte range of the attribute value
+	value      string // the attribute value
+	ref        string // the subresource, relative to the asset directory
+}
+
+// linkIntegrity records the subresources whose hashes the HTML file at
+// relPath carries, so it is updated when they are transformed again
+func (t *Transformer) linkIntegrity(relPath string, refs []string) {
+	t.integrityMu.Lock()
+	defer t.integrityMu.Unlock()
+
+	if len(refs) == 0 {
+		delete(t.integrity, relPath)
+		return
+	}
+	t.integrity[relPath] = refs
+}
+
+// refreshIntegrity updates the integrity attributes of cached HTML files
+// that reference the subresource at relPath after it was transformed. Evicted
+// files are left alone; they are updated when transformed again.
+func (t *Transformer) refreshIntegrity(relPath string) {
+	t.integrityMu.Lock()
+	var pages []string
+	for page, refs := range t.integrity {
+		for _, ref := range refs {
+			if ref == relPath {
+				pages = append(pages, page)
+				break
+			}
+		}
+	}
+	t.integrityMu.Unlock()
+
+	for _, page := range pages {
+		entry, ok := t.cache.resident(page)
+		if !ok {
+			continue
+		}
+
+		content, _ := t.applyIntegrity(page, entry.Content)
+		if bytes.Equal(content, entry.Content) {
+			continue
+		}
+
+		updated := &Entry{
+			Content:      content,
+			Unresolved:   entry.Unresolved,
+			Placeholders: entry.Placeholders,
+			SourceMap:    entry.SourceMap,
+			Transformed:  true,
+		}
+		_ = t.finishEntry(page, updated) // reported when the page was transformed
+		t.precompress(updated)
+		t.cache.SetEntry(page, updated)
+	}
+}
+
+// applyIntegrity recomputes the integrity attributes in the HTML file at
+// relPath for subresources that are transformed, so browsers still accept
+// them. Subresources that are served as-is keep their hashes, as do
+// subresources not transformed yet. It returns the content and every
+// subresource with an integrity attribute.
+func (t *Transformer) applyIntegrity(relPath string, content []byte) ([]byte, []string) {
+	attrs := findIntegrity(relPath, content)
+	if len(attrs) == 0 {
+		return content, nil
+	}
+
+	var result []byte
+	refs := make([]string, 0, len(attrs))
+	last := 0
+	for _, attr := range attrs {
+		refs = append(refs, attr.ref)
+
+		// Pages never load pages as subresources, and rebuilding one here
+		// could loop through the loader
+		if isHTMLPath(attr.ref) {
+			continue
+		}
+
+		// Files rendered per request have no single hash
+		entry, ok := t.cache.getEntry(attr.ref, false)
+		if !ok || entry.Template != nil {
+			continue
+		}
+
+		value := integrityValue(attr.value, entry.Content)
+		if value == attr.value {
+			continue
+		}
+
+		t.log().Debug("Updated subresource integrity", "path", relPath, "subresource", attr.ref)
+		result = append(result, content[last:attr.start]...)
+		result = append(result, value...)
+		last = attr.end
+	}
+
+	if result == nil {
+		return content, refs
+	}
+	return append(result, content[last:]...), refs
+}
+
+// findIntegrity returns the integrity attributes of script and link tags in
+// content that reference a file in the asset directory
+func findIntegrity(relPath string, content []byte) []integrityAttr {
+	var attrs []integrityAttr
+
+	for _, tag := range integrityTagPattern.FindAllSubmatchIndex(content, -1) {
+		// Attributes start after the element name
+		element := strings.ToLower(string(content[tag[2]:tag[3]]))
+		nameEnd := tag[3]
+
+		integrity := integrityAttr{start: -1}
+		var ref string
+		for _, m := range attributePattern.FindAllSubmatchIndex(content[nameEnd:tag[1]], -1) {
+			name := strings.ToLower(string(content[nameEnd+m[2] : nameEnd+m[3]]))
+
+			// The value is in whichever of the quoted or unquoted groups matched
+			start, end := -1, -1
+			for g := 4; g < len(m); g += 2 {
+				if m[g] >= 0 {
+					start, end = nameEnd+m[g], nameEnd+m[g+1]
+					break
+				}
+			}
+			if start < 0 {
+				continue
+			}
+
+			switch {
+			case name == "integrity":
+				integrity.start, integrity.end = start, end
+				integrity.value = string(content[start:end])
+			case name == "src" && element == "script", name == "href" && element == "link":
+				ref = html.UnescapeString(string(content[start:end]))
+			}
+		}
+
+		if integrity.start < 0 || ref == "" {
+			continue
+		}
+		if resolved, ok := assetRef(relPath, ref); ok {
+			integrity.ref = resolved
+			attrs = append(attrs, integrity)
+		}
+	}
+
+	return attrs
+}
+
+// integrityValue recomputes every hash of integrity metadata for content,
+// keeping the algorithms and options. Hashes with unknown algorithms are kept
+// as they are.
+func integrityValue(value string, content []byte) string {
+	fields := strings.Fields(value)
+	changed := false
+
+	for i, field := range fields {
+		alg, rest, ok := strings.Cut(field, "-")
+		newHash, known := integrityHashes[strings.ToLower(alg)]
+		if !ok || !known {
+			continue
+		}
+
+		options := ""
+		if j := strings.IndexByte(rest, '?'); j >= 0 {
+			options = rest[j:]
+		}
+
+		h := newHash()
+		h.Write(content)
+		updated := alg + "-" + base64.StdEncoding.EncodeToString(h.Sum(nil)) + options
+		if updated != field {
+			fields[i] = updated
+			changed = true
+		}
+	}
+
+	if !changed {
+		return value
+	}
+	return strings.Join(fields, " ")
+}
+
+// isHTMLPath reports whether relPath is an HTML page
+func isHTMLPath(relPath string) bool {
+	switch strings.ToLower(path.Ext(relPath)) {
+	case ".html", ".htm":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,210 @@
+package transformer
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func sha384Integrity(content string) string {
+	sum := sha512.Sum384([]byte(content))
+	return "sha384-" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func sha256Integrity(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return "sha256-" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func sha512Integrity(content string) string {
+	sum := sha512.Sum512([]byte(content))
+	return "sha512-" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// writeIntegrityAssets creates a page referencing subresources that are
+// walked both before and after it
+func writeIntegrityAssets(t *testing.T) string {
+	t.Helper()
+	assetDir := t.TempDir()
+	files := map[string]string{
+		"assets/early.js": "const api = '__API__';",
+		"static/app.js":   "fetch('__API__');",
+		"static/site.css": "body { color: __COLOR__; }",
+		"static/lib.wasm": "\x00asm",
+		"index.html": `<script src="assets/early.js" integrity="` + sha384Integrity("const api = '__API__';") + `"></script>
+<script src="/static/app.js" integrity="` + sha384Integrity("fetch('__API__');") + `" crossorigin="anonymous"></script>
+<link rel="stylesheet" href="static/site.css?v=2" integrity='` + sha256Integrity("body { color: __COLOR__; }") + ` sha512-old'>
+<script src="https://cdn.example.com/lib.js" integrity="sha384-external"></script>
+<link rel="preload" href="static/lib.wasm" integrity="sha384-untouched">`,
+	}
+	for name, content := range files {
+		path := filepath.Join(assetDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to create file: %v", err)
+		}
+	}
+	return assetDir
+}
+
+var integrityReplacements = map[string]string{"API": "https://api.example.com", "COLOR": "red"}
+
+func TestTransformAllUpdatesIntegrity(t *testing.T) {
+	trans := New(writeIntegrityAssets(t), integrityReplacements, WithCompression(true))
+	if err := trans.TransformAll(); err != nil {
+		t.Fatalf("TransformAll failed: %v", err)
+	}
+
+	entry, ok := trans.GetCache().GetEntry("index.html")
+	if !ok {
+		t.Fatal("expected index.html to be cached")
+	}
+	page := string(entry.Content)
+
+	expected := []string{
+		`integrity="` + sha384Integrity("const api = 'https://api.example.com';") + `"`,
+		`integrity="` + sha384Integrity("fetch('https://api.example.com');") + `" crossorigin`,
+		`integrity='` + sha256Integrity("body { color: red; }") + " " + sha512Integrity("body { color: red; }") + `'`,
+		`integrity="sha384-external"`,
+		`integrity="sha384-untouched"`,
+	}
+	for _, want := range expected {
+		if !strings.Contains(page, want) {
+			t.Errorf("expected %s in:\n%s", want, page)
+		}
+	}
+
+	if entry.ETag != ETag(entry.Content) {
+		t.Error("expected ETag to match the updated content")
+	}
+}
+
+func TestIntegrityAfterEviction(t *testing.T) {
+	// A limit small enough to evict everything but the last file
+	trans := New(writeIntegrityAssets(t), integrityReplacements, WithCacheLimit(1))
+	if err := trans.TransformAll(); err != nil {
+		t.Fatalf("TransformAll failed: %v", err)
+	}
+
+	content, ok := trans.GetCache().Get("index.html")
+	if !ok {
+		t.Fatal("expected index.html to be reloaded")
+	}
+	want := sha384Integrity("fetch('https://api.example.com');")
+	if !strings.Contains(string(content), want) {
+		t.Errorf("expected %s in reloaded page:\n%s", want, content)
+	}
+}
+
+func TestIntegrityAfterReload(t *testing.T) {
+	assetDir := writeIntegrityAssets(t)
+	trans := New(assetDir, integrityReplacements)
+	if err := trans.TransformAll(); err != nil {
+		t.Fatalf("TransformAll failed: %v", err)
+	}
+
+	path := filepath.Join(assetDir, "static", "app.js")
+	if err := os.WriteFile(path, []byte("fetch('__API__/v2');"), 0644); err != nil {
+		t.Fatalf("failed to update file: %v", err)
+	}
+	if err := trans.transformFile(path, "static/app.js"); err != nil {
+		t.Fatalf("transformFile failed: %v", err)
+	}
+
+	content, _ := trans.GetCache().Get("index.html")
+	want := sha384Integrity("fetch('https://api.example.com/v2');")
+	if !strings.Contains(string(content), want) {
+		t.Errorf("expected %s after reload:\n%s", want, content)
+	}
+}
+
+func TestFindIntegrity(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected []string // value and ref of each attribute
+	}{
+		{
+			name:     "script",
+			content:  `<script src="app.js" integrity="sha384-a"></script>`,
+			expected: []string{"sha384-a", "pages/app.js"},
+		},
+		{
+			name:     "link with unquoted values",
+			content:  `<LINK REL=stylesheet HREF=/site.css INTEGRITY=sha256-b>`,
+			expected: []string{"sha256-b", "site.css"},
+		},
+		{
+			name:     "escaped reference",
+			content:  `<script integrity='sha512-c' src='app.js?a=1&amp;b=2'></script>`,
+			expected: []string{"sha512-c", "pages/app.js"},
+		},
+		{
+			name:    "no integrity",
+			content: `<script src="app.js"></script>`,
+		},
+		{
+			name:    "external",
+			content: `<script src="https://cdn.example.com/app.js" integrity="sha384-a"></script>`,
+		},
+		{
+			name:    "outside asset directory",
+			content: `<script src="../../app.js" integrity="sha384-a"></script>`,
+		},
+		{
+			name:    "href on script",
+			content: `<script href="app.js" integrity="sha384-a"></script>`,
+		},
+		{
+			name:    "other element",
+			content: `<img src="logo.png" integrity="sha384-a">`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attrs := findIntegrity("pages/index.html", []byte(tt.content))
+
+			var got []string
+			for _, attr := range attrs {
+				got = append(got, attr.value, attr.ref)
+				if tt.content[attr.start:attr.end] != attr.value {
+					t.Errorf("expected range to hold %q, got %q", attr.value, tt.content[attr.start:attr.end])
+				}
+			}
+			if strings.Join(got, " ") != strings.Join(tt.expected, " ") {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestIntegrityValue(t *testing.T) {
+	content := []byte("alert(1)")
+
+	tests := []struct {
+		name     string
+		value    string
+		expected string
+	}{
+		{"single hash", "sha384-old", sha384Integrity("alert(1)")},
+		{"several hashes", "sha256-old  sha512-old", sha256Integrity("alert(1)") + " " + sha512Integrity("alert(1)")},
+		{"options kept", "sha256-old?opt", sha256Integrity("alert(1)") + "?opt"},
+		{"unknown algorithm", "md5-old", "md5-old"},
+		{"up to date", sha384Integrity("alert(1)"), sha384Integrity("alert(1)")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := integrityValue(tt.value, content); got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,247 @@
+package transformer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// NoncePlaceholder is the name of the placeholder replaced with the
+// per-response nonce, e.g. __CSP_NONCE__ with the default delimiters
+const NoncePlaceholder = "CSP_NONCE"
+
+// errUnsafeRequestValue marks transform errors caused by request
+// placeholders where their value cannot be escaped; the file is still
+// transformed and cached, with those placeholders rendered empty
+var errUnsafeRequestValue = errors.New("request placeholder in an unescaped context")
+
+// nonceTagPattern matches the start tags of elements that take a nonce
+var nonceTagPattern = regexp.MustCompile(`(?i)<(script|style)\b[^>]*>`)
+
+// closeTagPatterns match the end tags of the elements that take a nonce
+var closeTagPatterns = map[string]*regexp.Regexp{
+	"script": regexp.MustCompile(`(?i)</script`),
+	"style":  regexp.MustCompile(`(?i)</style`),
+}
+
+// WithCSPNonce marks where a per-response Content-Security-Policy nonce goes
+// in cached HTML files: in a nonce attribute added to every script and style
+// tag, and in place of the CSP_NONCE placeholder. Entries with nonces are not
+// precompressed, as every response differs.
+func WithCSPNonce(enabled bool) Option {
+	return func(t *Transformer) {
+		t.nonce = enabled
+	}
+}
+
+// WithRequestPlaceholders leaves the given placeholders (names without
+// delimiters) in the transformed files, to be filled in per request from
+// the request itself. Only files containing them are rendered per request.
+func WithRequestPlaceholders(names ...string) Option {
+	return func(t *Transformer) {
+		t.request = append(t.request, names...)
+	}
+}
+
+// Template is a cached file rendered for each response: the CSP nonce and
+// request placeholders are filled in between fixed parts of its content
+type Template struct {
+	parts [][]byte // content between the slots, one more than there are slots
+	slots []slot
+	nonce bool // responses carry a CSP nonce
+}
+
+// slot is a value filled in per response
+type slot struct {
+	name     string // request placeholder name, empty for the CSP nonce
+	filters  []string
+	modifier byte         // 0 or '-' (default); required markers are ignored
+	arg      string       // default value
+	ctx      valueContext // context the value is escaped for
+}
+
+// cut is a place in content filled by a slot: the bytes from start to end
+// are replaced with prefix, the slot's value and suffix
+type cut struct {
+	start, end     int
+	prefix, suffix string
+	slot           slot
+}
+
+// Nonce reports whether responses carry a CSP nonce
+func (tpl *Template) Nonce() bool {
+	return tpl.nonce
+}
+
+// Names returns the request placeholders used, in order of first use
+func (tpl *Template) Names() []string {
+	var names []string
+	for _, s := range tpl.slots {
+		if s.name != "" && !slices.Contains(names, s.name) {
+			names = append(names, s.name)
+		}
+	}
+	return names
+}
+
+// Execute renders a response with nonce and the request values by name.
+// Values are filtered and escaped like configured values; missing values
+// are empty unless the placeholder has a default.
+func (tpl *Template) Execute(nonce string, values map[string]string) []byte {
+	out := make([]byte, 0, tpl.size()+len(tpl.slots)*len(nonce))
+	for i, s := range tpl.slots {
+		out = append(out, tpl.parts[i]...)
+		if s.name == "" {
+			out = append(out, nonce...)
+		} else {
+			out = append(out, s.render(values[s.name])...)
+		}
+	}
+	return append(out, tpl.parts[len(tpl.slots)]...)
+}
+
+// size returns the memory held by the template's parts
+func (tpl *Template) size() int {
+	size := 0
+	for _, part := range tpl.parts {
+		size += len(part)
+	}
+	return size
+}
+
+// render returns the text for a request value
+func (s slot) render(value string) string {
+	if value == "" && s.modifier == '-' {
+		// Defaults written in the source are inserted as-is
+		return applyFilters(s.arg, s.filters)
+	}
+	value = applyFilters(value, s.filters)
+	if preEncoded(s.filters, s.ctx) {
+		return value
+	}
+	return escapeRequestValue(s.ctx, value)
+}
+
+// escapeRequestValue escapes a request value for ctx. Outside strings, in
+// JavaScript code or JSON, the value becomes a string literal; in any other
+// raw context it is dropped, as finishEntry refuses such placeholders.
+func escapeRequestValue(ctx valueContext, value string) string {
+	switch ctx {
+	case ctxJSCode, ctxJSONValue:
+		return jsonQuote(value)
+	case ctxRaw:
+		return ""
+	default:
+		return escapeValue(ctx, value)
+	}
+}
+
+// finishEntry prepares the template of an entry with per-response values,
+// if it has any. It returns an error naming
+// request placeholders in contexts where a value cannot be escaped, such as
+// CSS, plain text or comments; those are rendered empty.
+func (t *Transformer) finishEntry(relPath string, entry *Entry) error {
+	nonce := t.nonce && isHTMLPath(relPath)
+
+	var cuts []cut
+	var unsafe []string
+	if nonce {
+		cuts = append(cuts, nonceCuts(entry.Content, []byte(t.open+NoncePlaceholder+t.close))...)
+	}
+	if t.requestEngine != nil {
+		// Request values come from the client, so they are always escaped,
+		// whatever the escaping settings
+		for _, c := range t.requestEngine.replace(entry.Content, newContextTracker(relPath)).cuts {
+			if c.slot.ctx == ctxRaw {
+				unsafe = append(unsafe, c.slot.name)
+			}
+			cuts = append(cuts, c)
+		}
+	}
+
+	if nonce || len(cuts) > 0 {
+		entry.Template = newTemplate(entry.Content, cuts, nonce)
+	}
+
+	if len(unsafe) > 0 {
+		return fmt.Errorf("%w: %s", errUnsafeRequestValue, strings.Join(unsafe, ", "))
+	}
+	return nil
+}
+
+// newTemplate splits content at the cuts
+func newTemplate(content []byte, cuts []cut, nonce bool) *Template {
+	sort.Slice(cuts, func(i, j int) bool { return cuts[i].start < cuts[j].start })
+
+	tpl := &Template{
+		parts: make([][]byte, 0, len(cuts)+1),
+		slots: make([]slot, 0, len(cuts)),
+		nonce: nonce,
+	}
+
+	var part []byte
+	last := 0
+	for _, c := range cuts {
+		part = append(part, content[last:c.start]...)
+		part = append(part, c.prefix...)
+		tpl.parts = append(tpl.parts, part)
+		tpl.slots = append(tpl.slots, c.slot)
+		part = []byte(c.suffix)
+		last = c.end
+	}
+	tpl.parts = append(tpl.parts, append(part, content[last:]...))
+
+	return tpl
+}
+
+// nonceCuts returns the places the nonce goes in content: a nonce attribute
+// for script and style tags without one, and every token
+func nonceCuts(content, token []byte) []cut {
+	var cuts []cut
+
+	for pos := 0; pos < len(content); {
+		loc := nonceTagPattern.FindSubmatchIndex(content[pos:])
+		if loc == nil {
+			break
+		}
+		nameEnd, tagEnd := pos+loc[3], pos+loc[1]
+		element := strings.ToLower(string(content[pos+loc[2] : nameEnd]))
+
+		if !hasAttribute(content[nameEnd:tagEnd], "nonce") {
+			cuts = append(cuts, cut{start: nameEnd, end: nameEnd, prefix: ` nonce="`, suffix: `"`})
+		}
+
+		// Tags written by scripts or inside styles are text, not elements
+		pos = tagEnd
+		if end := closeTagPatterns[element].FindIndex(content[pos:]); end != nil {
+			pos += end[0]
+		} else {
+			pos = len(content)
+		}
+	}
+
+	for pos := 0; ; {
+		i := bytes.Index(content[pos:], token)
+		if i < 0 {
+			break
+		}
+		cuts = append(cuts, cut{start: pos + i, end: pos + i + len(token)})
+		pos += i + len(token)
+	}
+
+	return cuts
+}
+
+// hasAttribute reports whether the attributes of a start tag include name
+func hasAttribute(attrs []byte, name string) bool {
+	for _, m := range attributePattern.FindAllSubmatch(attrs, -1) {
+		if strings.EqualFold(string(m[1]), name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,237 @@
+package transformer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNonceCuts(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected string // rendered with nonce "N"
+	}{
+		{
+			name:     "script and style tags",
+			content:  `<style>p{}</style><script src="app.js"></script>`,
+			expected: `<style nonce="N">p{}</style><script nonce="N" src="app.js"></script>`,
+		},
+		{
+			name:     "upper case tags",
+			content:  `<SCRIPT>x()</SCRIPT>`,
+			expected: `<SCRIPT nonce="N">x()</SCRIPT>`,
+		},
+		{
+			name:     "placeholder",
+			content:  `<meta name="csp-nonce" content="__CSP_NONCE__"><script nonce="__CSP_NONCE__">x()</script>`,
+			expected: `<meta name="csp-nonce" content="N"><script nonce="N">x()</script>`,
+		},
+		{
+			name:     "existing nonce kept",
+			content:  `<script nonce="fixed">x()</script>`,
+			expected: `<script nonce="fixed">x()</script>`,
+		},
+		{
+			name:     "tags written by scripts",
+			content:  `<script>document.write("<script src=x></script>")</script><script>y()</script>`,
+			expected: `<script nonce="N">document.write("<script src=x></script>")</script><script nonce="N">y()</script>`,
+		},
+		{
+			name:     "similar elements",
+			content:  `<scripts></scripts><styles>`,
+			expected: `<scripts></scripts><styles>`,
+		},
+		{
+			name:     "unclosed script",
+			content:  `<script>x()`,
+			expected: `<script nonce="N">x()`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := []byte(tt.content)
+			tpl := newTemplate(content, nonceCuts(content, []byte("__CSP_NONCE__")), true)
+			if got := string(tpl.Execute("N", nil)); got != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestTransformAllWithCSPNonce(t *testing.T) {
+	assetDir := t.TempDir()
+	page := "<html><head><script>window.api = '__API__';</script></head>" +
+		"<body data-nonce=\"${CSP_NONCE}\">" + strings.Repeat("<p>padding</p>", 200) + "</body></html>"
+	files := map[string]string{
+		"index.html": page,
+		"app.js":     "fetch('${API}'); " + strings.Repeat("// padding\n", 200),
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(assetDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to create file: %v", err)
+		}
+	}
+
+	trans := New(assetDir, map[string]string{"API": "https://x"},
+		WithCSPNonce(true), WithCompression(true), WithDelimiters("${", "}"))
+	if err := trans.TransformAll(); err != nil {
+		t.Fatalf("TransformAll failed: %v", err)
+	}
+
+	entry, ok := trans.GetCache().GetEntry("index.html")
+	if !ok {
+		t.Fatal("expected index.html to be cached")
+	}
+	if entry.Template == nil || !entry.Template.Nonce() || len(entry.Template.slots) != 2 {
+		t.Fatalf("expected the script tag and the placeholder to take the nonce, got %+v", entry.Template)
+	}
+	if entry.Gzip != nil || entry.Brotli != nil {
+		t.Error("expected pages with nonces not to be precompressed")
+	}
+
+	got := string(entry.Template.Execute("abc", nil))
+	if !strings.Contains(got, `<script nonce="abc">`) || !strings.Contains(got, `data-nonce="abc"`) {
+		t.Errorf("expected nonces in page, got %s", got[:120])
+	}
+
+	if unresolved := trans.GetCache().Unresolved(); len(unresolved) != 0 {
+		t.Errorf("expected the nonce placeholder not to be reported, got %+v", unresolved)
+	}
+
+	script, _ := trans.GetCache().GetEntry("app.js")
+	if script.Template != nil || script.Gzip == nil {
+		t.Error("expected only HTML pages to take nonces")
+	}
+}
+
+func TestTransformAllWithRequestPlaceholders(t *testing.T) {
+	assetDir := t.TempDir()
+	files := map[string]string{
+		"index.html":  `<h1>__TENANT|upper__</h1><script>var region = "__REGION:-eu__";</script><p>__API__</p>`,
+		"config.json": `{"tenant": "__TENANT__", "api": "__API__"}`,
+		"app.js":      "fetch('__API__'); " + strings.Repeat("// padding\n", 200),
+		"region.js":   "var region = __REGION__;",
+		"tenant.json": `{"tenant": __TENANT__}`,
+		"links.html":  `<a href="/?t=__TENANT|urlencode__">x</a><script>var t = __TENANT|json__;</script>`,
+		"region.html": `<div class=__REGION__ id=main>`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(assetDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to create file: %v", err)
+		}
+	}
+
+	trans := New(assetDir, map[string]string{"API": "https://x", "TENANT": "ignored"},
+		WithRequestPlaceholders("TENANT", "REGION"), WithCompression(true))
+	if err := trans.TransformAll(); err != nil {
+		t.Fatalf("TransformAll failed: %v", err)
+	}
+
+	tests := []struct {
+		path     string
+		values   map[string]string
+		expected string
+	}{
+		{
+			path:     "index.html",
+			values:   map[string]string{"TENANT": "acme<b>", "REGION": `us"`},
+			expected: `<h1>ACME&lt;B&gt;</h1><script>var region = "us\"";</script><p>https://x</p>`,
+		},
+		{
+			path:     "index.html",
+			values:   nil,
+			expected: `<h1></h1><script>var region = "eu";</script><p>https://x</p>`,
+		},
+		{
+			path:     "config.json",
+			values:   map[string]string{"TENANT": `a"b`},
+			expected: `{"tenant": "a\"b", "api": "https://x"}`,
+		},
+		{
+			path:     "region.js",
+			values:   map[string]string{"REGION": "1;alert(document.cookie)//</script>"},
+			expected: `var region = "1;alert(document.cookie)//\u003c/script\u003e";`,
+		},
+		{
+			path:     "tenant.json",
+			values:   map[string]string{"TENANT": `1, "admin": true`},
+			expected: `{"tenant": "1, \"admin\": true"}`,
+		},
+		{
+			path:     "links.html",
+			values:   map[string]string{"TENANT": `a b"`},
+			expected: `<a href="/?t=a+b%22">x</a><script>var t = "a b\"";</script>`,
+		},
+		{
+			path:     "region.html",
+			values:   map[string]string{"REGION": "x onmouseover=alert(document.domain)"},
+			expected: `<div class=x&#x20;onmouseover&#x3D;alert&#x28;document.domain&#x29; id=main>`,
+		},
+	}
+
+	for _, tt := range tests {
+		entry, ok := trans.GetCache().GetEntry(tt.path)
+		if !ok || entry.Template == nil {
+			t.Fatalf("%s: expected a template", tt.path)
+		}
+		if entry.Template.Nonce() {
+			t.Errorf("%s: expected no nonce without WithCSPNonce", tt.path)
+		}
+		if got := string(entry.Template.Execute("", tt.values)); got != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.path, tt.expected, got)
+		}
+	}
+
+	page, _ := trans.GetCache().GetEntry("index.html")
+	if names := page.Template.Names(); len(names) != 2 || names[0] != "TENANT" || names[1] != "REGION" {
+		t.Errorf("expected TENANT and REGION in order, got %v", names)
+	}
+	if page.Gzip != nil {
+		t.Error("expected files rendered per request not to be precompressed")
+	}
+
+	script, _ := trans.GetCache().GetEntry("app.js")
+	if script.Template != nil || script.Gzip == nil {
+		t.Error("expected files without request placeholders to be served as cached")
+	}
+
+	if unresolved := trans.GetCache().Unresolved(); len(unresolved) != 0 {
+		t.Errorf("expected request placeholders not to be reported, got %+v", unresolved)
+	}
+}
+
+func TestTransformAllRefusesUnescapedRequestPlaceholders(t *testing.T) {
+	files := map[string]string{
+		"styles.css":  "body { color: __REGION__; }",
+		"notes.txt":   "Region: __REGION__",
+		"comment.js":  "var x = 1; // __REGION__",
+		"style.html":  "<style>p { color: __REGION__; }</style>",
+		"comment.htm": "<!-- __REGION__ -->",
+	}
+
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			assetDir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(assetDir, name), []byte(content), 0644); err != nil {
+				t.Fatalf("failed to create file: %v", err)
+			}
+
+			trans := New(assetDir, map[string]string{}, WithRequestPlaceholders("REGION"))
+			err := trans.TransformAll()
+			if err == nil || !strings.Contains(err.Error(), name+": ") || !strings.Contains(err.Error(), "REGION") {
+				t.Fatalf("expected an error naming %s and REGION, got %v", name, err)
+			}
+
+			entry, ok := trans.GetCache().GetEntry(name)
+			if !ok || entry.Template == nil {
+				t.Fatal("expected the file to still be cached")
+			}
+			if got := string(entry.Template.Execute("", map[string]string{"REGION": "red}</style><script>"})); strings.Contains(got, "red") {
+				t.Errorf("expected the value to be left out, got %s", got)
+			}
+		})
+	}
+}
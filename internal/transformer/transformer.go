@@ -1,98 +1,518 @@
 package transformer
 
 import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io/fs"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 )
 
-// Cache stores transformed file contents in memory
+// Entry is a transformed file held in the cache
+type Entry struct {
+	Content []byte
+
+	// Precompressed variants of Content, nil when compression is disabled or
+	// would not make the file smaller
+	Gzip   []byte
+	Brotli []byte
+
+	// ETag is a strong validator derived from a hash of Content
+	ETag string
+	// ModTime is when the entry was transformed, used for Last-Modified
+	ModTime time.Time
+
+	// Unresolved lists placeholder tokens still present in Content
+	Unresolved []Unresolved
+
+	// Placeholders counts the placeholders found in the original file by name
+	Placeholders map[string]int
+
+	// Transformed reports whether Content differs from the file on disk, in
+	// which case it may change without the file changing
+	Transformed bool
+
+	// SourceMap is the path of the source map referenced by Content, which
+	// is corrected for the replacements made
+	SourceMap string
+
+	// Template renders Content for each response when it has values filled
+	// in per request, a CSP nonce or request placeholders; nil otherwise
+	Template *Template
+}
+
+// Size returns the memory held by the entry's content and its variants
+func (e *Entry) Size() int {
+	size := len(e.Content) + len(e.Gzip) + len(e.Brotli)
+	if e.Template != nil {
+		size += e.Template.size()
+	}
+	return size
+}
+
+// ETag returns a strong entity tag for content
+func ETag(content []byte) string {
+	sum := sha256.Sum256(content)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// Cache stores transformed file contents in memory.
+//
+// With a memory budget, the least recently used entries are evicted once the
+// budget is exceeded. Evicted files stay known to the cache and are
+// transformed again from disk by the loader on their next request, so they
+// are never served untransformed.
 type Cache struct {
-	mu     sync.RWMutex
-	files  map[string][]byte // map of file path -> transformed content
-	hits   uint64            // cache hit counter
-	misses uint64            // cache miss counter
+	mu       sync.Mutex
+	files    map[string]*cacheItem // map of file path -> cached item
+	lru      *list.List            // resident items, most recently used first
+	bytes    int                   // memory held by resident entries
+	maxBytes int                   // memory budget, 0 for unlimited
+	loader   func(path string) (*Entry, error)
+
+	hits      uint64 // cache hit counter
+	misses    uint64 // cache miss counter
+	evictions uint64 // entries evicted to stay within the budget
+	loads     uint64 // evicted entries transformed again on request
+	reloads   uint64 // hot reload counter
+}
+
+// cacheItem is a file known to the cache. Its entry is nil while evicted;
+// the audit and usage results are kept so evictions don't hide them.
+type cacheItem struct {
+	path         string
+	entry        *Entry
+	unresolved   []Unresolved
+	placeholders map[string]int
+	elem         *list.Element // position in the LRU list while resident
+}
+
+// CacheStats is a snapshot of cache counters
+type CacheStats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	Loads     uint64
+	Files     int // files known to the cache, resident or evicted
+	Resident  int // files currently held in memory
+	SizeBytes int // memory held by resident entries
+	MaxBytes  int // memory budget, 0 for unlimited
 }
 
 // NewCache creates a new cache instance
 func NewCache() *Cache {
 	return &Cache{
-		files: make(map[string][]byte),
+		files: make(map[string]*cacheItem),
+		lru:   list.New(),
 	}
 }
 
+// SetMaxBytes sets the memory budget, evicting entries if it is already
+// exceeded. Zero disables the budget.
+func (c *Cache) SetMaxBytes(maxBytes int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.maxBytes = maxBytes
+	c.evict()
+}
+
+// SetLoader sets the function used to rebuild evicted entries
+func (c *Cache) SetLoader(loader func(path string) (*Entry, error)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.loader = loader
+}
+
 // Get retrieves transformed content from cache
 func (c *Cache) Get(path string) ([]byte, bool) {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-	content, exists := c.files[path]
+	entry, exists := c.GetEntry(path)
+	if !exists {
+		return nil, false
+	}
+	return entry.Content, true
+}
 
-	if exists {
-		atomic.AddUint64(&c.hits, 1)
-	} else {
+// GetEntry retrieves a transformed entry from cache, rebuilding it with the
+// loader if it was evicted
+func (c *Cache) GetEntry(path string) (*Entry, bool) {
+	return c.getEntry(path, true)
+}
+
+// getEntry is GetEntry, only counting hits and misses if count is set so
+// lookups made while transforming don't skew the stats
+func (c *Cache) getEntry(path string, count bool) (*Entry, bool) {
+	c.mu.Lock()
+	item, exists := c.files[path]
+	if exists && item.entry != nil {
+		// Copied under the lock, as evictions clear item.entry
+		entry := item.entry
+		c.lru.MoveToFront(item.elem)
+		c.mu.Unlock()
+		if count {
+			atomic.AddUint64(&c.hits, 1)
+		}
+		return entry, true
+	}
+	loader := c.loader
+	c.mu.Unlock()
+
+	if count {
 		atomic.AddUint64(&c.misses, 1)
 	}
+	if !exists || loader == nil {
+		return nil, false
+	}
+
+	// Transform outside the lock so other requests aren't blocked on disk
+	entry, err := loader(path)
+	if err != nil {
+		slog.Warn("Failed to reload evicted file", "path", path, "error", err)
+		c.mu.Lock()
+		if c.files[path] == item && item.entry == nil {
+			delete(c.files, path)
+		}
+		c.mu.Unlock()
+		return nil, false
+	}
+	atomic.AddUint64(&c.loads, 1)
 
-	return content, exists
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch current := c.files[path]; {
+	case current == nil:
+		// Removed while loading
+		return nil, false
+	case current.entry != nil:
+		// Updated or loaded concurrently; prefer what is already cached
+		c.lru.MoveToFront(current.elem)
+		return current.entry, true
+	}
+	c.store(path, entry)
+	return entry, true
+}
+
+// resident returns the entry for path if it is held in memory, without
+// loading it or counting a hit
+func (c *Cache) resident(path string) (*Entry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	item, exists := c.files[path]
+	if !exists || item.entry == nil {
+		return nil, false
+	}
+	return item.entry, true
 }
 
 // Set stores transformed content in cache
 func (c *Cache) Set(path string, content []byte) {
+	c.SetEntry(path, &Entry{Content: content})
+}
+
+// SetEntry stores a transformed entry in cache, filling in its validators
+// if they are not set yet
+func (c *Cache) SetEntry(path string, entry *Entry) {
+	fillValidators(entry)
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	c.files[path] = content
+	c.store(path, entry)
 }
 
-// Size returns the number of cached files
+// fillValidators sets the ETag and modification time of an entry if they
+// are not set yet
+func fillValidators(entry *Entry) {
+	if entry.ETag == "" {
+		entry.ETag = ETag(entry.Content)
+	}
+	if entry.ModTime.IsZero() {
+		// HTTP dates have second precision
+		entry.ModTime = time.Now().Truncate(time.Second)
+	}
+}
+
+// store makes entry the resident, most recently used entry for path and
+// evicts others to stay within the budget. The caller holds the lock.
+func (c *Cache) store(path string, entry *Entry) {
+	item, exists := c.files[path]
+	if !exists {
+		item = &cacheItem{path: path}
+		c.files[path] = item
+	}
+
+	if item.entry != nil {
+		c.bytes -= item.entry.Size()
+		c.lru.MoveToFront(item.elem)
+	} else {
+		item.elem = c.lru.PushFront(item)
+	}
+
+	item.entry = entry
+	item.unresolved = entry.Unresolved
+	item.placeholders = entry.Placeholders
+	c.bytes += entry.Size()
+
+	c.evict()
+}
+
+// replaceEntry stores entry for path in place of old, if old is still the
+// resident entry; otherwise entry is outdated and dropped
+func (c *Cache) replaceEntry(path string, old, entry *Entry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if item, ok := c.files[path]; ok && item.entry == old {
+		c.store(path, entry)
+	}
+}
+
+// evict drops least recently used entries until the budget is met. The most
+// recently used entry is always kept, even if it exceeds the budget alone.
+// The caller holds the lock.
+func (c *Cache) evict() {
+	if c.maxBytes <= 0 {
+		return
+	}
+
+	for c.bytes > c.maxBytes && c.lru.Len() > 1 {
+		item := c.lru.Remove(c.lru.Back()).(*cacheItem)
+		c.bytes -= item.entry.Size()
+		item.entry = nil
+		item.elem = nil
+		atomic.AddUint64(&c.evictions, 1)
+	}
+}
+
+// Remove deletes a file from the cache. If path names a directory, every
+// cached file below it is removed. It returns the number of removed entries.
+func (c *Cache) Remove(path string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	removed := 0
+	prefix := path + "/"
+	for key, item := range c.files {
+		if key == path || strings.HasPrefix(key, prefix) {
+			if item.entry != nil {
+				c.bytes -= item.entry.Size()
+				c.lru.Remove(item.elem)
+			}
+			delete(c.files, key)
+			removed++
+		}
+	}
+
+	return removed
+}
+
+// MarkReloaded records that the cache was updated by a hot reload
+func (c *Cache) MarkReloaded() {
+	atomic.AddUint64(&c.reloads, 1)
+}
+
+// Reloads returns how many hot reloads have been applied to the cache
+func (c *Cache) Reloads() uint64 {
+	return atomic.LoadUint64(&c.reloads)
+}
+
+// Unresolved returns all unresolved placeholders across cached files,
+// ordered by file and line. Evicted files are included.
+func (c *Cache) Unresolved() []Unresolved {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	result := []Unresolved{}
+	for _, item := range c.files {
+		result = append(result, item.unresolved...)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].File != result[j].File {
+			return result[i].File < result[j].File
+		}
+		return result[i].Line < result[j].Line
+	})
+
+	return result
+}
+
+// Size returns the number of cached files, including evicted ones
 func (c *Cache) Size() int {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	return len(c.files)
 }
 
 // Stats returns cache statistics
-func (c *Cache) Stats() (hits, misses uint64, sizeBytes int) {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
+func (c *Cache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-	for _, content := range c.files {
-		sizeBytes += len(content)
+	return CacheStats{
+		Hits:      atomic.LoadUint64(&c.hits),
+		Misses:    atomic.LoadUint64(&c.misses),
+		Evictions: atomic.LoadUint64(&c.evictions),
+		Loads:     atomic.LoadUint64(&c.loads),
+		Files:     len(c.files),
+		Resident:  c.lru.Len(),
+		SizeBytes: c.bytes,
+		MaxBytes:  c.maxBytes,
 	}
-
-	return atomic.LoadUint64(&c.hits), atomic.LoadUint64(&c.misses), sizeBytes
 }
 
 // Transformer handles asset transformation
 type Transformer struct {
-	assetDir     string
-	replacements map[string]string
-	cache        *Cache
+	host          string // virtual host served from the cache, for logs
+	assetDir      string
+	replacements  map[string]string
+	ignored       map[string]bool // placeholder names never reported as unresolved
+	runtimeEnv    []string        // patterns of replacements exposed through the runtime env
+	rules         Rules
+	compress      bool            // precompress cached files with gzip and brotli
+	nonce         bool            // mark where CSP nonces go in HTML files
+	request       []string        // placeholder names filled in per request
+	escape        bool            // escape values for the context they land in
+	raw           map[string]bool // placeholder names inserted without escaping
+	open, close   string          // placeholder delimiters
+	engine        *engine
+	requestEngine *engine        // finds request placeholders in transformed files
+	audit         *regexp.Regexp // matches placeholders left after transformation
+	cache         *Cache
+
+	// sourceMaps holds the edits to correct each linked source map for
+	sourceMapsMu sync.Mutex
+	sourceMaps   map[string][]lineEdit
+
+	// integrity holds the subresources each HTML file carries hashes of
+	integrityMu sync.Mutex
+	integrity   map[string][]string
+}
+
+// Option configures optional Transformer behavior
+type Option func(*Transformer)
+
+// WithCacheLimit bounds the memory held by cached files to maxBytes, evicting
+// the least recently used files and transforming them again when requested.
+// Zero means unlimited.
+func WithCacheLimit(maxBytes int) Option {
+	return func(t *Transformer) {
+		t.cache.SetMaxBytes(maxBytes)
+	}
+}
+
+// WithDelimiters sets the text that opens and closes placeholders, e.g. "${"
+// and "}" for ${NAME}, instead of the default "__" and "__". Empty delimiters
+// are ignored.
+func WithDelimiters(open, close string) Option {
+	return func(t *Transformer) {
+		if open != "" && close != "" {
+			t.open, t.close = open, close
+		}
+	}
+}
+
+// WithIgnoredPlaceholders excludes the given names (without delimiters) from
+// the unresolved placeholder audit, in addition to DefaultIgnoredPlaceholders
+func WithIgnoredPlaceholders(names ...string) Option {
+	return func(t *Transformer) {
+		for _, name := range names {
+			t.ignored[name] = true
+		}
+	}
+}
+
+// WithHost names the virtual host the transformer prepares assets for, so
+// its log messages can be told apart from those of other hosts
+func WithHost(host string) Option {
+	return func(t *Transformer) {
+		t.host = host
+	}
 }
 
 // New creates a new Transformer instance
-func New(assetDir string, replacements map[string]string) *Transformer {
-	return &Transformer{
+func New(assetDir string, replacements map[string]string, opts ...Option) *Transformer {
+	t := &Transformer{
 		assetDir:     assetDir,
 		replacements: replacements,
+		ignored:      make(map[string]bool),
+		escape:       true,
+		raw:          make(map[string]bool),
 		cache:        NewCache(),
+		sourceMaps:   make(map[string][]lineEdit),
+		integrity:    make(map[string][]string),
+		open:         "__",
+		close:        "__",
+	}
+
+	for _, name := range DefaultIgnoredPlaceholders {
+		t.ignored[name] = true
+	}
+
+	for _, opt := range opts {
+		opt(t)
 	}
+
+	// The nonce placeholder is filled in per response
+	if t.nonce {
+		t.ignored[NoncePlaceholder] = true
+	}
+
+	t.engine = newEngine(t.replacements)
+	t.engine.open, t.engine.close = []byte(t.open), []byte(t.close)
+	t.engine.raw = t.raw
+
+	// Request placeholders stay in place until the template pass
+	if len(t.request) > 0 {
+		t.engine.addRequestNames(t.request)
+		t.requestEngine = newEngine(nil)
+		t.requestEngine.open, t.requestEngine.close = t.engine.open, t.engine.close
+		t.requestEngine.addRequestNames(t.request)
+		for _, name := range t.request {
+			t.ignored[name] = true
+		}
+	}
+	t.audit = unresolvedPattern(t.open, t.close)
+	t.cache.SetLoader(t.loadEntry)
+
+	return t
+}
+
+// placeholderError sums up the placeholder errors of every file for
+// TransformAll, matching the kind of error they are with errors.Is
+type placeholderError struct {
+	kind    error
+	message string
+}
+
+func (e *placeholderError) Error() string {
+	return e.message
+}
+
+func (e *placeholderError) Unwrap() error {
+	return e.kind
 }
 
 // TransformAll scans the asset directory and transforms all applicable files
 func (t *Transformer) TransformAll() error {
-	slog.Info("Starting asset transformation", "assetDir", t.assetDir, "replacements", len(t.replacements))
+	t.log().Info("Starting asset transformation", "assetDir", t.assetDir, "replacements", len(t.replacements))
 
 	if len(t.replacements) == 0 {
-		slog.Warn("No STAGE_* environment variables found, no transformations will be applied")
-		return nil
+		t.log().Warn("No STAGE_* environment variables found, only placeholder defaults will be applied")
 	}
 
 	transformCount := 0
+	var missing, invalid, unsafe []string
 	err := filepath.WalkDir(t.assetDir, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
@@ -103,32 +523,37 @@ func (t *Transformer) TransformAll() error {
 			return nil
 		}
 
+		// Store in cache (using relative path from asset directory)
+		relPath, err := t.relativePath(path)
+		if err != nil {
+			t.log().Error("Failed to get relative path", "path", path, "error", err)
+			return err
+		}
+
 		// Only transform text-based files that might contain placeholders
-		if !shouldTransform(path) {
+		if !t.selectFile(path, relPath) {
 			return nil
 		}
 
-		// Read the file
-		content, err := os.ReadFile(path)
-		if err != nil {
-			slog.Error("Failed to read file, skipping", "path", path, "error", err)
+		// Apply transformations, collecting required placeholders without a
+		// value and unknown filters
+		if err := t.transformFile(path, relPath); err != nil {
+			if errors.Is(err, errMissingRequired) {
+				missing = append(missing, fmt.Sprintf("%s: %v", relPath, err))
+				return nil
+			}
+			if errors.Is(err, errUnknownFilter) {
+				invalid = append(invalid, fmt.Sprintf("%s: %v", relPath, err))
+				return nil
+			}
+			if errors.Is(err, errUnsafeRequestValue) {
+				unsafe = append(unsafe, fmt.Sprintf("%s: %v", relPath, err))
+				return nil
+			}
+			t.log().Error("Failed to read file, skipping", "path", path, "error", err)
 			return nil // Continue with other files
 		}
 
-		// Apply transformations
-		transformed := t.transform(content)
-
-		// Store in cache (using relative path from asset directory)
-		relPath, err := filepath.Rel(t.assetDir, path)
-		if err != nil {
-			slog.Error("Failed to get relative path", "path", path, "error", err)
-			return err
-		}
-
-		// Normalize path separators for cross-platform compatibility
-		relPath = filepath.ToSlash(relPath)
-
-		t.cache.Set(relPath, transformed)
 		transformCount++
 
 		return nil
@@ -138,32 +563,212 @@ func (t *Transformer) TransformAll() error {
 		return fmt.Errorf("failed to transform assets: %w", err)
 	}
 
+	if len(missing) > 0 {
+		return &placeholderError{errMissingRequired,
+			"required placeholders have no value: " + strings.Join(missing, "; ")}
+	}
+
+	if len(invalid) > 0 {
+		return &placeholderError{errUnknownFilter,
+			"placeholders use unknown filters: " + strings.Join(invalid, "; ")}
+	}
+
+	if len(unsafe) > 0 {
+		return &placeholderError{errUnsafeRequestValue,
+			"request placeholders cannot be escaped where they appear: " + strings.Join(unsafe, "; ")}
+	}
+
 	// Get cache statistics and warn if cache is large
-	_, _, sizeBytes := t.cache.Stats()
-	sizeMB := sizeBytes / (1024 * 1024)
+	stats := t.cache.Stats()
+	sizeMB := stats.SizeBytes / (1024 * 1024)
+
+	t.log().Info("Asset transformation complete", "filesTransformed", transformCount, "cachedFiles", stats.Files, "cacheSizeMB", sizeMB)
+
+	if stats.Evictions > 0 {
+		t.log().Info("Cache memory budget reached, least recently used files will be transformed on request",
+			"residentFiles", stats.Resident, "evictions", stats.Evictions, "cacheLimitMB", stats.MaxBytes/(1024*1024))
+	}
+
+	t.logUsage()
 
-	slog.Info("Asset transformation complete", "filesTransformed", transformCount, "cachedFiles", t.cache.Size(), "cacheSizeMB", sizeMB)
+	if unresolved := t.cache.Unresolved(); len(unresolved) > 0 {
+		t.log().Warn("Transformed assets still contain placeholders", "count", len(unresolved))
+	}
 
 	const warnThresholdMB = 100
-	if sizeMB > warnThresholdMB {
-		slog.Warn("Cache size is large, consider reviewing asset directory size", "cacheSizeMB", sizeMB, "thresholdMB", warnThresholdMB)
+	if stats.MaxBytes == 0 && sizeMB > warnThresholdMB {
+		t.log().Warn("Cache size is large, consider setting a cache limit", "cacheSizeMB", sizeMB, "thresholdMB", warnThresholdMB)
 	}
 
 	return nil
 }
 
-// transform applies string replacements to content
-func (t *Transformer) transform(content []byte) []byte {
-	contentStr := string(content)
+// errMissingRequired marks transform errors caused by required placeholders
+// that have no value; the file is still transformed and cached
+var errMissingRequired = errors.New("missing value for required placeholder")
+
+// transformFile reads, transforms and audits a single file and stores the
+// result in the cache under relPath. A source map the file references is
+// corrected for the replacements made, and the integrity attributes of HTML
+// files referencing it are updated.
+func (t *Transformer) transformFile(path, relPath string) error {
+	entry, err := t.cacheFile(path, relPath)
+	if entry != nil && entry.SourceMap != "" {
+		t.refreshSourceMap(entry.SourceMap)
+	}
+	if entry != nil {
+		t.refreshIntegrity(relPath)
+	}
+	return err
+}
+
+// cacheFile builds the entry for a single file, logs its audit and stores it
+// in the cache
+func (t *Transformer) cacheFile(path, relPath string) (*Entry, error) {
+	entry, err := t.buildEntry(path, relPath)
+	if entry == nil {
+		return nil, err
+	}
+	if err != nil {
+		t.log().Error("Placeholders could not be replaced", "path", relPath, "error", err)
+	}
+	t.precompress(entry)
+
+	for _, u := range entry.Unresolved {
+		t.log().Warn("Unresolved placeholder", "path", u.File, "line", u.Line, "token", u.Token)
+	}
+
+	t.cache.SetEntry(relPath, entry)
+
+	return entry, err
+}
+
+// buildEntry reads, transforms, audits and compresses a single file. If only
+// required placeholders are missing, both the entry and the error are returned.
+func (t *Transformer) buildEntry(path, relPath string) (*Entry, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Linked source maps only have placeholders replaced if selected by rules
+	mapEdits, isSourceMap := t.sourceMapEdits(relPath)
+	result := replaceResult{content: content}
+	var transformErr error
+	if !isSourceMap || t.selectText(path, relPath) {
+		result, transformErr = t.replace(relPath, content)
+	}
+
+	transformed := result.content
+	if isSourceMap {
+		corrected, err := correctSourceMap(transformed, mapEdits)
+		if err != nil {
+			t.log().Warn("Failed to correct source map, serving it uncorrected", "path", relPath, "error", err)
+		} else {
+			transformed = corrected
+		}
+	}
+
+	// Subresources changed by replacements no longer match their hashes
+	if isHTMLPath(relPath) {
+		var refs []string
+		transformed, refs = t.applyIntegrity(relPath, transformed)
+		t.linkIntegrity(relPath, refs)
+	}
+
+	// Audit the output for placeholders that are still present
+	entry := &Entry{
+		Content:      transformed,
+		Unresolved:   findUnresolved(t.audit, relPath, transformed, t.ignored),
+		Placeholders: result.found,
+		Transformed:  !bytes.Equal(transformed, content),
+	}
+
+	if mapPath, ok := sourceMapPath(relPath, transformed); ok && !isSourceMap {
+		entry.SourceMap = mapPath
+		t.linkSourceMap(mapPath, lineEdits(content, result.edits))
+	}
+
+	if err := t.finishEntry(relPath, entry); err != nil && transformErr == nil {
+		transformErr = err
+	}
+
+	return entry, transformErr
+}
+
+// loadEntry rebuilds an evicted cache entry from disk. The request waiting
+// for it gets it uncompressed; the compressed variants follow in the
+// background.
+func (t *Transformer) loadEntry(relPath string) (*Entry, error) {
+	entry, err := t.buildEntry(t.absolutePath(relPath), relPath)
+	if entry == nil {
+		return nil, err
+	}
+	fillValidators(entry)
+	if t.compress && entry.Template == nil && len(entry.Content) >= compressMinSize {
+		go t.compressLoaded(relPath, entry)
+	}
+	return entry, nil
+}
+
+// log returns the logger for messages about this transformer's assets
+func (t *Transformer) log() *slog.Logger {
+	if t.host == "" {
+		return slog.Default()
+	}
+	return slog.Default().With("host", t.host)
+}
+
+// relativePath converts a file system path into a cache key relative to the
+// asset directory, using forward slashes on every platform
+func (t *Transformer) relativePath(path string) (string, error) {
+	relPath, err := filepath.Rel(t.assetDir, path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.ToSlash(relPath), nil
+}
+
+// absolutePath converts a cache key back into a file system path
+func (t *Transformer) absolutePath(relPath string) string {
+	return filepath.Join(t.assetDir, filepath.FromSlash(relPath))
+}
+
+// transform applies replacements to content in a single pass. Placeholders
+// may carry a fallback modifier:
+//
+//	__NAME:-default__  use "default" when NAME is unset or empty
+//	__NAME:?message__  NAME is required; "message" explains what it is for
+//
+// Values are escaped for the context they appear in, based on the file type
+// of relPath, unless escaping is disabled or the placeholder is raw.
+//
+// It returns an error listing any required placeholders that have no value;
+// the returned content is still fully transformed in that case, with the
+// required placeholders left as-is.
+func (t *Transformer) transform(relPath string, content []byte) ([]byte, error) {
+	result, err := t.replace(relPath, content)
+	return result.content, err
+}
+
+// replace is transform, also returning the edits made to content
+func (t *Transformer) replace(relPath string, content []byte) (replaceResult, error) {
+	var tracker contextTracker
+	if t.escape {
+		tracker = newContextTracker(relPath)
+	}
+
+	result := t.engine.replace(content, tracker)
+
+	if len(result.missing) > 0 {
+		return result, fmt.Errorf("%w: %s", errMissingRequired, strings.Join(result.missing, ", "))
+	}
 
-	// Apply each replacement
-	for placeholder, value := range t.replacements {
-		// Create the full placeholder pattern: __PLACEHOLDER__
-		pattern := fmt.Sprintf("__%s__", placeholder)
-		contentStr = strings.ReplaceAll(contentStr, pattern, value)
+	if len(result.invalid) > 0 {
+		return result, fmt.Errorf("%w: %s", errUnknownFilter, strings.Join(result.invalid, ", "))
 	}
 
-	return []byte(contentStr)
+	return result, nil
 }
 
 // GetCache returns the transformation cache
@@ -171,7 +776,8 @@ func (t *Transformer) GetCache() *Cache {
 	return t.cache
 }
 
-// shouldTransform determines if a file should be transformed based on extension
+// shouldTransform determines if a file should be transformed based on its
+// extension alone; Rules can extend or narrow this list
 func shouldTransform(path string) bool {
 	ext := strings.ToLower(filepath.Ext(path))
 
@@ -4,9 +4,11 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"sync"
 	"testing"
+	"time"
 )
 
 func TestNewCache(t *testing.T) {
@@ -124,12 +126,57 @@ func TestTransform(t *testing.T) {
 			},
 			expected: "replaced __ff_sdk_key__",
 		},
+		{
+			name:         "default used when unset",
+			content:      "const url = '__API_ENDPOINT:-http://localhost:3000__';",
+			replacements: map[string]string{},
+			expected:     "const url = 'http://localhost:3000';",
+		},
+		{
+			name:    "default ignored when set",
+			content: "const url = '__API_ENDPOINT:-http://localhost:3000__';",
+			replacements: map[string]string{
+				"API_ENDPOINT": "https://api.test.com",
+			},
+			expected: "const url = 'https://api.test.com';",
+		},
+		{
+			name:    "default used when empty",
+			content: "const name = '__APP_NAME:-My App__';",
+			replacements: map[string]string{
+				"APP_NAME": "",
+			},
+			expected: "const name = 'My App';",
+		},
+		{
+			name:         "empty default",
+			content:      "const flags = '__FLAGS:-__';",
+			replacements: map[string]string{},
+			expected:     "const flags = '';",
+		},
+		{
+			name:    "required placeholder with value",
+			content: "const key = '__FF_SDK_KEY:?SDK key for feature flags__';",
+			replacements: map[string]string{
+				"FF_SDK_KEY": "test-123",
+			},
+			expected: "const key = 'test-123';",
+		},
+		{
+			name:         "unknown bare placeholder left untouched",
+			content:      "__webpack_require__.__DEV__",
+			replacements: map[string]string{},
+			expected:     "__webpack_require__.__DEV__",
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			trans := New("/tmp", tt.replacements)
-			result := trans.transform([]byte(tt.content))
+			result, err := trans.transform("", []byte(tt.content))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
 
 			if string(result) != tt.expected {
 				t.Errorf("expected:\n%s\ngot:\n%s", tt.expected, string(result))
@@ -138,6 +185,27 @@ func TestTransform(t *testing.T) {
 	}
 }
 
+func TestTransformRequiredPlaceholder(t *testing.T) {
+	trans := New("/tmp", map[string]string{})
+
+	content := "const key = '__FF_SDK_KEY:?SDK key for feature flags__'; const id = '__APP_ID:?__';"
+	result, err := trans.transform("", []byte(content))
+	if err == nil {
+		t.Fatal("expected error for missing required placeholders")
+	}
+
+	for _, want := range []string{"FF_SDK_KEY (SDK key for feature flags)", "APP_ID"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error to mention %q, got: %v", want, err)
+		}
+	}
+
+	// Required placeholders are left in place so the failure is visible
+	if string(result) != content {
+		t.Errorf("expected content to be unchanged, got: %s", result)
+	}
+}
+
 func TestShouldTransform(t *testing.T) {
 	tests := []struct {
 		filename string
@@ -261,25 +329,94 @@ func TestTransformAll(t *testing.T) {
 	}
 }
 
+func TestTransformAllMarksTransformed(t *testing.T) {
+	tempDir := t.TempDir()
+	files := map[string]string{
+		"main-BdH3kx9Q.js": "fetch('__API__');",
+		"lib-CdH3kx9Q.js":  "export const answer = 42;",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to create test file %s: %v", name, err)
+		}
+	}
+
+	trans := New(tempDir, map[string]string{"API": "https://x"})
+	if err := trans.TransformAll(); err != nil {
+		t.Fatalf("TransformAll failed: %v", err)
+	}
+
+	if entry, _ := trans.GetCache().GetEntry("main-BdH3kx9Q.js"); !entry.Transformed {
+		t.Error("expected a file with replacements to be marked transformed")
+	}
+	if entry, _ := trans.GetCache().GetEntry("lib-CdH3kx9Q.js"); entry.Transformed {
+		t.Error("expected a file cached unchanged not to be marked transformed")
+	}
+}
+
 func TestTransformAllWithNoReplacements(t *testing.T) {
 	tempDir := t.TempDir()
 
-	// Create a test file
+	// Create a test file that relies on a placeholder default
 	testFile := filepath.Join(tempDir, "test.html")
-	os.WriteFile(testFile, []byte("<html>test</html>"), 0644)
+	os.WriteFile(testFile, []byte("<html>__TITLE:-Local__</html>"), 0644)
 
 	// Create transformer with no replacements
 	trans := New(tempDir, map[string]string{})
 
-	// Should not error, just log a warning and return early
+	// Should not error, just log a warning and apply defaults
 	err := trans.TransformAll()
 	if err != nil {
 		t.Errorf("TransformAll should not error with no replacements: %v", err)
 	}
 
-	// Cache should be empty since TransformAll returns early when no replacements exist
-	if trans.GetCache().Size() != 0 {
-		t.Errorf("expected empty cache when no replacements configured, got %d items", trans.GetCache().Size())
+	content, exists := trans.GetCache().Get("test.html")
+	if !exists {
+		t.Fatal("expected test.html to be cached")
+	}
+
+	if string(content) != "<html>Local</html>" {
+		t.Errorf("expected default to be applied, got %s", content)
+	}
+}
+
+func TestTransformAllWithDelimiters(t *testing.T) {
+	tempDir := t.TempDir()
+	content := "const a = '${API}', b = '${MISSING}', c = window.__DEV__;"
+	os.WriteFile(filepath.Join(tempDir, "app.js"), []byte(content), 0644)
+
+	trans := New(tempDir, map[string]string{"API": "https://x", "DEV": "oops"}, WithDelimiters("${", "}"))
+	if err := trans.TransformAll(); err != nil {
+		t.Fatalf("TransformAll failed: %v", err)
+	}
+
+	result, _ := trans.GetCache().Get("app.js")
+	if expected := "const a = 'https://x', b = '${MISSING}', c = window.__DEV__;"; string(result) != expected {
+		t.Errorf("expected %s, got %s", expected, result)
+	}
+
+	// The audit looks for the configured delimiters
+	unresolved := trans.GetCache().Unresolved()
+	if len(unresolved) != 1 || unresolved[0].Token != "${MISSING}" {
+		t.Errorf("expected ${MISSING} to be reported, got %+v", unresolved)
+	}
+}
+
+func TestTransformAllWithMissingRequired(t *testing.T) {
+	tempDir := t.TempDir()
+
+	os.WriteFile(filepath.Join(tempDir, "app.js"), []byte("const key = '__FF_SDK_KEY:?__';"), 0644)
+	os.WriteFile(filepath.Join(tempDir, "other.js"), []byte("const ok = true;"), 0644)
+
+	trans := New(tempDir, map[string]string{"OTHER": "value"})
+
+	err := trans.TransformAll()
+	if err == nil {
+		t.Fatal("expected error for missing required placeholder")
+	}
+
+	if !strings.Contains(err.Error(), "app.js") || !strings.Contains(err.Error(), "FF_SDK_KEY") {
+		t.Errorf("expected error to name file and placeholder, got: %v", err)
 	}
 }
 
@@ -344,7 +481,7 @@ func TestCacheConcurrency(t *testing.T) {
 				_ = cache.Size()
 
 				// Check stats (triggers read lock)
-				_, _, _ = cache.Stats()
+				_ = cache.Stats()
 			}
 		}(i)
 	}
@@ -357,7 +494,8 @@ func TestCacheConcurrency(t *testing.T) {
 	}
 
 	// Verify stats are tracked
-	hits, misses, sizeBytes := cache.Stats()
+	stats := cache.Stats()
+	hits, misses, sizeBytes := stats.Hits, stats.Misses, stats.SizeBytes
 	if hits == 0 {
 		t.Error("expected some cache hits")
 	}
@@ -371,7 +509,8 @@ func TestCacheStats(t *testing.T) {
 	cache := NewCache()
 
 	// Initially, stats should be zero
-	hits, misses, sizeBytes := cache.Stats()
+	stats := cache.Stats()
+	hits, misses, sizeBytes := stats.Hits, stats.Misses, stats.SizeBytes
 	if hits != 0 || misses != 0 || sizeBytes != 0 {
 		t.Errorf("expected zero stats for empty cache, got hits=%d, misses=%d, bytes=%d", hits, misses, sizeBytes)
 	}
@@ -387,7 +526,8 @@ func TestCacheStats(t *testing.T) {
 	cache.Get("nonexistent.html")
 
 	// Check stats
-	hits, misses, sizeBytes = cache.Stats()
+	stats = cache.Stats()
+	hits, misses, sizeBytes = stats.Hits, stats.Misses, stats.SizeBytes
 	if hits != 1 {
 		t.Errorf("expected 1 hit, got %d", hits)
 	}
@@ -401,6 +541,218 @@ func TestCacheStats(t *testing.T) {
 	}
 }
 
+func TestCacheSetEntryValidators(t *testing.T) {
+	cache := NewCache()
+	cache.Set("a.js", []byte("same"))
+	cache.Set("b.js", []byte("same"))
+	cache.Set("c.js", []byte("different"))
+
+	a, _ := cache.GetEntry("a.js")
+	b, _ := cache.GetEntry("b.js")
+	c, _ := cache.GetEntry("c.js")
+
+	if a.ETag == "" || a.ETag[0] != '"' {
+		t.Fatalf("expected quoted ETag, got %q", a.ETag)
+	}
+	if a.ETag != b.ETag {
+		t.Error("expected identical content to share an ETag")
+	}
+	if a.ETag == c.ETag {
+		t.Error("expected different content to have different ETags")
+	}
+	if a.ModTime.IsZero() {
+		t.Error("expected ModTime to be set")
+	}
+
+	// Explicit validators are kept
+	cache.SetEntry("d.js", &Entry{Content: []byte("x"), ETag: `"custom"`})
+	if d, _ := cache.GetEntry("d.js"); d.ETag != `"custom"` {
+		t.Errorf("expected explicit ETag to be kept, got %q", d.ETag)
+	}
+}
+
+func TestCacheLRUEviction(t *testing.T) {
+	cache := NewCache()
+	cache.SetMaxBytes(10)
+
+	cache.Set("a.js", []byte("aaaa"))
+	cache.Set("b.js", []byte("bbbb"))
+	cache.Get("a.js") // a is now more recently used than b
+	cache.Set("c.js", []byte("cccc"))
+
+	stats := cache.Stats()
+	if stats.Evictions != 1 || stats.Resident != 2 || stats.SizeBytes != 8 {
+		t.Errorf("expected 1 eviction, 2 resident files and 8 bytes, got %+v", stats)
+	}
+	if stats.Files != 3 {
+		t.Errorf("expected evicted files to stay known, got %d files", stats.Files)
+	}
+
+	// Without a loader an evicted file is a miss
+	if _, ok := cache.Get("b.js"); ok {
+		t.Error("expected least recently used b.js to be evicted")
+	}
+	for _, path := range []string{"a.js", "c.js"} {
+		if _, ok := cache.Get(path); !ok {
+			t.Errorf("expected %s to be resident", path)
+		}
+	}
+
+	// Removing a resident file frees its memory
+	cache.Remove("a.js")
+	if stats := cache.Stats(); stats.SizeBytes != 4 || stats.Files != 2 {
+		t.Errorf("expected 4 bytes in 2 files after removal, got %+v", stats)
+	}
+}
+
+func TestCacheKeepsOversizedEntry(t *testing.T) {
+	cache := NewCache()
+	cache.SetMaxBytes(4)
+
+	cache.Set("small.js", []byte("ab"))
+	cache.Set("large.js", []byte("0123456789"))
+
+	if _, ok := cache.Get("large.js"); !ok {
+		t.Error("expected the most recent entry to be kept even above the budget")
+	}
+	if _, ok := cache.Get("small.js"); ok {
+		t.Error("expected older entries to be evicted")
+	}
+}
+
+func TestCacheConcurrentEviction(t *testing.T) {
+	cache := NewCache()
+	cache.SetMaxBytes(16)
+	cache.SetLoader(func(path string) (*Entry, error) {
+		return &Entry{Content: []byte("reloaded")}, nil
+	})
+
+	paths := []string{"a.js", "b.js", "c.js", "d.js"}
+	for _, path := range paths {
+		cache.Set(path, []byte("content"))
+	}
+
+	// Every get of one file evicts another, so gets race with evictions.
+	// More threads than CPUs let them interleave on small machines too.
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			for j := 0; j < 500; j++ {
+				path := paths[(id+j)%len(paths)]
+				if j%5 == 0 {
+					cache.Set(path, []byte("content"))
+					continue
+				}
+				if entry, ok := cache.GetEntry(path); ok && entry == nil {
+					t.Errorf("expected an entry for %s when found", path)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if stats := cache.Stats(); stats.Evictions == 0 {
+		t.Errorf("expected evictions under the budget, got %+v", stats)
+	}
+}
+
+func TestCacheLimitCompressesReloadsInBackground(t *testing.T) {
+	tmpDir := t.TempDir()
+	for _, name := range []string{"a.js", "b.js"} {
+		content := "var value = '__VALUE__';\n" + strings.Repeat("// padding\n", 200)
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to create file: %v", err)
+		}
+	}
+
+	// Room for a single compressed file
+	trans := New(tmpDir, map[string]string{"VALUE": "v"}, WithCompression(true), WithCacheLimit(3000))
+	if err := trans.TransformAll(); err != nil {
+		t.Fatalf("TransformAll failed: %v", err)
+	}
+	cache := trans.GetCache()
+
+	var evicted string
+	for _, name := range []string{"a.js", "b.js"} {
+		if _, ok := cache.resident(name); !ok {
+			evicted = name
+		}
+	}
+	if evicted == "" {
+		t.Fatal("expected a file to be evicted")
+	}
+
+	entry, ok := cache.GetEntry(evicted)
+	if !ok || entry.ETag == "" {
+		t.Fatalf("expected %s to be reloaded with an ETag, got %+v", evicted, entry)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if entry, _ := cache.GetEntry(evicted); entry.Gzip != nil && entry.Brotli != nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %s to be compressed after reloading", evicted)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestCacheLimitReloadsEvictedFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	for _, name := range []string{"a.js", "b.js", "c.js"} {
+		content := fmt.Sprintf("var %s = '__VALUE__'; // __MISSING__", strings.TrimSuffix(name, ".js"))
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to create file: %v", err)
+		}
+	}
+
+	// Room for a single transformed file
+	trans := New(tmpDir, map[string]string{"VALUE": "v"}, WithCacheLimit(40))
+	if err := trans.TransformAll(); err != nil {
+		t.Fatalf("TransformAll failed: %v", err)
+	}
+	cache := trans.GetCache()
+
+	if stats := cache.Stats(); stats.Resident != 1 || stats.Evictions != 2 {
+		t.Fatalf("expected 1 resident file after 2 evictions, got %+v", stats)
+	}
+
+	// Evictions don't hide the audit
+	if unresolved := cache.Unresolved(); len(unresolved) != 3 {
+		t.Errorf("expected unresolved placeholders of all 3 files, got %d", len(unresolved))
+	}
+
+	// Evicted files are transformed again, never served from disk as-is
+	for _, name := range []string{"a.js", "b.js", "c.js"} {
+		content, ok := cache.Get(name)
+		if !ok {
+			t.Fatalf("expected %s to be reloaded", name)
+		}
+		if !strings.Contains(string(content), "= 'v'") {
+			t.Errorf("expected %s to be transformed, got %s", name, content)
+		}
+	}
+
+	if stats := cache.Stats(); stats.Loads != 3 || stats.Resident != 1 {
+		t.Errorf("expected 3 loads with 1 resident file, got %+v", stats)
+	}
+
+	// A file deleted while evicted is forgotten
+	os.Remove(filepath.Join(tmpDir, "a.js"))
+	if _, ok := cache.Get("a.js"); ok {
+		t.Error("expected deleted file to be a miss")
+	}
+	if cache.Size() != 2 {
+		t.Errorf("expected deleted file to be dropped from the cache, got %d files", cache.Size())
+	}
+}
+
 // Helper functions
 
 func containsPlaceholder(content string) bool {
@@ -0,0 +1,108 @@
+package transformer
+
+import (
+	"path"
+	"sort"
+)
+
+// UsageReport shows which files each placeholder was found in
+type UsageReport struct {
+	Placeholders []PlaceholderUsage `json:"placeholders"`
+	// Unused lists configured replacements that matched no placeholder and
+	// are not exposed through the runtime env either
+	Unused []string `json:"unused"`
+}
+
+// PlaceholderUsage counts the occurrences of one placeholder name
+type PlaceholderUsage struct {
+	Name string `json:"name"`
+	// Configured is false for placeholders resolved by a default or left
+	// unresolved because no replacement is set
+	Configured  bool        `json:"configured"`
+	Occurrences int         `json:"occurrences"`
+	Files       []FileUsage `json:"files"`
+}
+
+// FileUsage counts the occurrences of a placeholder in one file
+type FileUsage struct {
+	File  string `json:"file"`
+	Count int    `json:"count"`
+}
+
+// UsageReport aggregates the placeholders found in cached files, including
+// evicted ones, against the configured replacements. Replacements matching
+// the runtimeEnv allowlist patterns are used by /__stage/env.js, so they are
+// never unused. Placeholders are ordered by name and files by path.
+func (c *Cache) UsageReport(replacements map[string]string, runtimeEnv []string) UsageReport {
+	c.mu.Lock()
+	byName := make(map[string]*PlaceholderUsage)
+	for path, item := range c.files {
+		for name, count := range item.placeholders {
+			usage, ok := byName[name]
+			if !ok {
+				_, configured := replacements[name]
+				usage = &PlaceholderUsage{Name: name, Configured: configured}
+				byName[name] = usage
+			}
+			usage.Occurrences += count
+			usage.Files = append(usage.Files, FileUsage{File: path, Count: count})
+		}
+	}
+	c.mu.Unlock()
+
+	report := UsageReport{
+		Placeholders: []PlaceholderUsage{},
+		Unused:       []string{},
+	}
+
+	for _, usage := range byName {
+		sort.Slice(usage.Files, func(i, j int) bool {
+			return usage.Files[i].File < usage.Files[j].File
+		})
+		report.Placeholders = append(report.Placeholders, *usage)
+	}
+	sort.Slice(report.Placeholders, func(i, j int) bool {
+		return report.Placeholders[i].Name < report.Placeholders[j].Name
+	})
+
+	for name := range replacements {
+		if _, ok := byName[name]; !ok && !matchesAny(runtimeEnv, name) {
+			report.Unused = append(report.Unused, name)
+		}
+	}
+	sort.Strings(report.Unused)
+
+	return report
+}
+
+// logUsage summarizes placeholder usage after a transformation
+func (t *Transformer) logUsage() {
+	report := t.cache.UsageReport(t.replacements, t.runtimeEnv)
+
+	for _, usage := range report.Placeholders {
+		t.log().Info("Placeholder usage", "name", usage.Name, "configured", usage.Configured,
+			"files", len(usage.Files), "occurrences", usage.Occurrences)
+	}
+
+	for _, name := range report.Unused {
+		t.log().Warn("Replacement matched no placeholders", "name", name)
+	}
+}
+
+// WithRuntimeEnvAllowlist names the replacements exposed through the runtime
+// env by glob pattern, which the usage report does not count as unused
+func WithRuntimeEnvAllowlist(patterns ...string) Option {
+	return func(t *Transformer) {
+		t.runtimeEnv = append(t.runtimeEnv, patterns...)
+	}
+}
+
+// matchesAny reports whether name matches any of the glob patterns
+func matchesAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+package transformer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUsageReport(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"index.html":   "<title>__TITLE__</title><script>var a='__API__'</script>",
+		"js/app.js":    "fetch('__API__/users');fetch('__API__/orders');var l='__LOCALE:-en__';",
+		"js/vendor.js": "var x = 1;",
+	}
+	for name, content := range files {
+		path := filepath.Join(tmpDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to create file: %v", err)
+		}
+	}
+
+	replacements := map[string]string{
+		"API":     "https://api.example.com",
+		"TITLE":   "Demo",
+		"UNUSED":  "x",
+		"ALSO_NO": "y",
+	}
+	trans := New(tmpDir, replacements)
+	if err := trans.TransformAll(); err != nil {
+		t.Fatalf("TransformAll failed: %v", err)
+	}
+
+	report := trans.GetCache().UsageReport(replacements, nil)
+
+	expected := []PlaceholderUsage{
+		{Name: "API", Configured: true, Occurrences: 3, Files: []FileUsage{{"index.html", 1}, {"js/app.js", 2}}},
+		{Name: "LOCALE", Configured: false, Occurrences: 1, Files: []FileUsage{{"js/app.js", 1}}},
+		{Name: "TITLE", Configured: true, Occurrences: 1, Files: []FileUsage{{"index.html", 1}}},
+	}
+
+	if len(report.Placeholders) != len(expected) {
+		t.Fatalf("expected %d placeholders, got %+v", len(expected), report.Placeholders)
+	}
+	for i, want := range expected {
+		got := report.Placeholders[i]
+		if got.Name != want.Name || got.Configured != want.Configured || got.Occurrences != want.Occurrences {
+			t.Errorf("placeholder %d: expected %+v, got %+v", i, want, got)
+			continue
+		}
+		if len(got.Files) != len(want.Files) {
+			t.Errorf("%s: expected files %v, got %v", want.Name, want.Files, got.Files)
+			continue
+		}
+		for j := range want.Files {
+			if got.Files[j] != want.Files[j] {
+				t.Errorf("%s: expected files %v, got %v", want.Name, want.Files, got.Files)
+			}
+		}
+	}
+
+	if len(report.Unused) != 2 || report.Unused[0] != "ALSO_NO" || report.Unused[1] != "UNUSED" {
+		t.Errorf("expected unused [ALSO_NO UNUSED], got %v", report.Unused)
+	}
+
+	// Replacements exposed through the runtime env are used there
+	report = trans.GetCache().UsageReport(replacements, []string{"ALSO_*"})
+	if len(report.Unused) != 1 || report.Unused[0] != "UNUSED" {
+		t.Errorf("expected unused [UNUSED] with ALSO_NO in the runtime env, got %v", report.Unused)
+	}
+}
+
+func TestUsageReportSurvivesEviction(t *testing.T) {
+	cache := NewCache()
+	cache.SetMaxBytes(1)
+	cache.SetEntry("a.js", &Entry{Content: []byte("aaaa"), Placeholders: map[string]int{"A": 1}})
+	cache.SetEntry("b.js", &Entry{Content: []byte("bbbb"), Placeholders: map[string]int{"B": 1}})
+
+	report := cache.UsageReport(nil, nil)
+	if len(report.Placeholders) != 2 {
+		t.Errorf("expected usage of evicted files to be kept, got %+v", report.Placeholders)
+	}
+	if report.Unused == nil {
+		t.Error("expected an empty, non-nil unused list")
+	}
+}
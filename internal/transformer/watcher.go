@@ -0,0 +1,163 @@
+package transformer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Watch keeps the cache in sync with the asset directory until ctx is done.
+// Added and changed files are re-transformed and deleted files are evicted.
+// Events are collected and applied as one reload once no further events
+// arrive for the debounce interval, so bursts of writes (e.g. kubectl cp)
+// only trigger a single reload.
+func (t *Transformer) Watch(ctx context.Context, debounce time.Duration) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create asset watcher: %w", err)
+	}
+	defer watcher.Close()
+
+	// fsnotify is not recursive, so every directory is watched individually
+	if err := addWatches(watcher, t.assetDir); err != nil {
+		return fmt.Errorf("failed to watch asset directory: %w", err)
+	}
+
+	t.log().Info("Watching asset directory for changes", "assetDir", t.assetDir, "debounce", debounce)
+
+	pending := make(map[string]bool)
+	timer := time.NewTimer(debounce)
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+
+			// Permission changes don't affect content
+			if event.Op == fsnotify.Chmod {
+				continue
+			}
+
+			t.log().Debug("Asset change detected", "path", event.Name, "op", event.Op.String())
+			pending[event.Name] = true
+			timer.Reset(debounce)
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			t.log().Error("Asset watcher error", "error", err)
+
+		case <-timer.C:
+			t.applyChanges(watcher, pending)
+			pending = make(map[string]bool)
+		}
+	}
+}
+
+// applyChanges re-transforms or evicts every changed path and records a
+// reload if the cache was modified
+func (t *Transformer) applyChanges(watcher *fsnotify.Watcher, changed map[string]bool) {
+	paths := make([]string, 0, len(changed))
+	for path := range changed {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	updated, evicted := 0, 0
+	for _, path := range paths {
+		relPath, err := t.relativePath(path)
+		if err != nil {
+			t.log().Error("Failed to get relative path", "path", path, "error", err)
+			continue
+		}
+
+		info, err := os.Stat(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			// Deleted or renamed away; directories evict everything below them
+			evicted += t.cache.Remove(relPath)
+			continue
+		}
+		if err != nil {
+			t.log().Error("Failed to stat changed file", "path", path, "error", err)
+			continue
+		}
+
+		if info.IsDir() {
+			// Files may have been written before the directory was watched
+			if err := addWatches(watcher, path); err != nil {
+				t.log().Error("Failed to watch new directory", "path", path, "error", err)
+			}
+			updated += t.transformTree(path)
+			continue
+		}
+
+		if !t.selectFile(path, relPath) {
+			continue
+		}
+
+		if err := t.transformFile(path, relPath); err != nil && !IsPlaceholderError(err) {
+			t.log().Error("Failed to reload file", "path", path, "error", err)
+			continue
+		}
+		updated++
+	}
+
+	if updated == 0 && evicted == 0 {
+		return
+	}
+
+	t.cache.MarkReloaded()
+	t.log().Info("Assets reloaded", "filesUpdated", updated, "filesEvicted", evicted, "reloads", t.cache.Reloads())
+}
+
+// transformTree transforms every applicable file below dir and returns how
+// many were cached
+func (t *Transformer) transformTree(dir string) int {
+	count := 0
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+
+		relPath, err := t.relativePath(path)
+		if err != nil || !t.selectFile(path, relPath) {
+			return nil
+		}
+
+		if err := t.transformFile(path, relPath); err != nil && !IsPlaceholderError(err) {
+			t.log().Error("Failed to reload file", "path", path, "error", err)
+			return nil
+		}
+		count++
+		return nil
+	})
+	return count
+}
+
+// addWatches registers root and all directories below it with the watcher
+func addWatches(watcher *fsnotify.Watcher, root string) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return watcher.Add(path)
+		}
+		return nil
+	})
+}
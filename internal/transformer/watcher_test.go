@@ -0,0 +1,149 @@
+package transformer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// waitFor polls condition until it holds or the timeout expires
+func waitFor(t *testing.T, description string, condition func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if condition() {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %s", description)
+}
+
+func TestWatchReloadsChangedFiles(t *testing.T) {
+	tempDir := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(tempDir, "app.js"), []byte("const key = '__KEY__';"), 0644); err != nil {
+		t.Fatalf("failed to create test file: %v", err)
+	}
+
+	trans := New(tempDir, map[string]string{"KEY": "value"})
+	if err := trans.TransformAll(); err != nil {
+		t.Fatalf("TransformAll failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- trans.Watch(ctx, 20*time.Millisecond)
+	}()
+	defer func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("Watch returned error: %v", err)
+		}
+	}()
+
+	cache := trans.GetCache()
+
+	// Give the watcher time to register the directory
+	time.Sleep(50 * time.Millisecond)
+
+	// Changed file is re-transformed
+	if err := os.WriteFile(filepath.Join(tempDir, "app.js"), []byte("const changed = '__KEY__';"), 0644); err != nil {
+		t.Fatalf("failed to update test file: %v", err)
+	}
+	waitFor(t, "changed file", func() bool {
+		content, _ := cache.Get("app.js")
+		return string(content) == "const changed = 'value';"
+	})
+
+	// New file in a new directory is transformed
+	if err := os.MkdirAll(filepath.Join(tempDir, "sub"), 0755); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tempDir, "sub", "new.html"), []byte("<p>__KEY__</p>"), 0644); err != nil {
+		t.Fatalf("failed to create test file: %v", err)
+	}
+	waitFor(t, "new file", func() bool {
+		content, _ := cache.Get("sub/new.html")
+		return string(content) == "<p>value</p>"
+	})
+
+	// Deleted file is evicted
+	if err := os.Remove(filepath.Join(tempDir, "app.js")); err != nil {
+		t.Fatalf("failed to remove test file: %v", err)
+	}
+	waitFor(t, "evicted file", func() bool {
+		_, exists := cache.Get("app.js")
+		return !exists
+	})
+
+	// Non-transformable files are ignored
+	if err := os.WriteFile(filepath.Join(tempDir, "logo.png"), []byte("__KEY__"), 0644); err != nil {
+		t.Fatalf("failed to create test file: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if _, exists := cache.Get("logo.png"); exists {
+		t.Error("expected logo.png not to be cached")
+	}
+
+	if cache.Reloads() == 0 {
+		t.Error("expected reloads to be counted")
+	}
+}
+
+func TestWatchDebouncesBursts(t *testing.T) {
+	tempDir := t.TempDir()
+
+	trans := New(tempDir, map[string]string{"KEY": "value"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go trans.Watch(ctx, 200*time.Millisecond)
+
+	time.Sleep(50 * time.Millisecond)
+
+	for i := 0; i < 10; i++ {
+		if err := os.WriteFile(filepath.Join(tempDir, "app.js"), []byte("const key = '__KEY__';"), 0644); err != nil {
+			t.Fatalf("failed to write test file: %v", err)
+		}
+	}
+
+	cache := trans.GetCache()
+	waitFor(t, "reload", func() bool {
+		return cache.Reloads() > 0
+	})
+
+	// Wait past another debounce interval to catch any extra reloads
+	time.Sleep(300 * time.Millisecond)
+	if reloads := cache.Reloads(); reloads != 1 {
+		t.Errorf("expected burst of writes to produce 1 reload, got %d", reloads)
+	}
+}
+
+func TestCacheRemove(t *testing.T) {
+	cache := NewCache()
+	cache.Set("index.html", []byte("index"))
+	cache.Set("assets/app.js", []byte("app"))
+	cache.Set("assets/css/app.css", []byte("css"))
+	cache.Set("assets-other.js", []byte("other"))
+
+	if removed := cache.Remove("assets"); removed != 2 {
+		t.Errorf("expected 2 entries removed for directory, got %d", removed)
+	}
+
+	if removed := cache.Remove("index.html"); removed != 1 {
+		t.Errorf("expected 1 entry removed for file, got %d", removed)
+	}
+
+	if _, exists := cache.Get("assets-other.js"); !exists {
+		t.Error("expected sibling with shared prefix to remain cached")
+	}
+
+	if cache.Size() != 1 {
+		t.Errorf("expected 1 entry left, got %d", cache.Size())
+	}
+}